@@ -1,14 +1,26 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"flag"
 	"fmt"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 	"net/http"
@@ -19,8 +31,12 @@ import (
 	"github.com/v8tix/eda/waiter"
 	"github.com/v8tix/eda/web"
 	"github.com/v8tix/mallbots-stores"
+	"github.com/v8tix/mallbots-stores-proto/pb"
 	"github.com/v8tix/mallbots-stores/internal/config"
+	"github.com/v8tix/mallbots-stores/internal/health"
+	"github.com/v8tix/mallbots-stores/internal/metrics"
 	"github.com/v8tix/mallbots-stores/internal/ms"
+	"github.com/v8tix/mallbots-stores/internal/rpc"
 )
 
 func main() {
@@ -34,7 +50,15 @@ func main() {
 
 	cfgFile := fmt.Sprintf("%s/%s", cfgDirFlag, cfgFileFlag)
 
-	if err := run(cfgFile, &cfg); err != nil {
+	var err error
+	switch flag.Arg(0) {
+	case "rebuild":
+		// rebuild [projection...]
+		err = rebuild(cfgFile, &cfg, flag.Args()[1:])
+	default:
+		err = run(cfgFile, &cfg)
+	}
+	if err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
 	}
@@ -45,7 +69,12 @@ func run(configFile string, cfg *config.AppConfig) (err error) {
 	if err != nil {
 		return err
 	}
-	m := app{cfg: *cfg}
+	m := app{
+		cfg:          *cfg,
+		webStopped:   make(chan struct{}),
+		rpcStopped:   make(chan struct{}),
+		adminStopped: make(chan struct{}),
+	}
 
 	// init infrastructure...
 	// init db
@@ -74,9 +103,17 @@ func run(configFile string, cfg *config.AppConfig) (err error) {
 		return err
 	}
 	m.logger = initLogger(cfg)
-	m.rpc = initRPC(cfg.RPC)
+	shutdownTracing, err := initTracing(cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer shutdownTracing()
+	m.interceptors = rpc.NewInterceptors()
+	m.rpc = initRPC(cfg.RPC, m.logger, m.interceptors)
 	m.mux = initMux(cfg.Web)
+	m.adminMux = chi.NewMux()
 	m.waiter = waiter.New(waiter.CatchSignals())
+	m.health = initHealth(m.db, m.nc)
 
 	// init modules
 	m.modules = []ms.Module{
@@ -87,7 +124,10 @@ func run(configFile string, cfg *config.AppConfig) (err error) {
 		return err
 	}
 
-	// Mount general web resources
+	// Mount health and general web resources
+	m.health.RegisterServer(m.rpc)
+	m.mux.Get("/healthz", m.health.Live)
+	m.mux.Get("/readyz", m.health.Ready)
 	m.mux.Mount("/", http.FileServer(http.FS(web.WebUI)))
 
 	fmt.Println("started mallbots application")
@@ -95,8 +135,10 @@ func run(configFile string, cfg *config.AppConfig) (err error) {
 
 	m.waiter.Add(
 		m.waitForWeb,
+		m.waitForAdmin,
 		m.waitForRPC,
 		m.waitForStream,
+		m.health.Run,
 	)
 
 	// go func() {
@@ -118,15 +160,93 @@ func initLogger(cfg *config.AppConfig) zerolog.Logger {
 	})
 }
 
-func initRPC(_ config.RPCConfig) *grpc.Server {
-	server := grpc.NewServer()
+// tracingShutdownTimeout bounds flushing the spans that are still buffered
+const tracingShutdownTimeout = 5 * time.Second
+
+// initTracing installs the tracer provider and the W3C trace context
+// propagator; the returned func flushes and stops the exporter
+func initTracing(cfg config.TracingConfig) (func(), error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "", "disabled":
+		return func() {}, nil
+	case "otlp":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptrace.New(context.Background(), otlptracegrpc.NewClient(opts...))
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "stores"
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			fmt.Println(err.Error())
+		}
+	}, nil
+}
+
+// initRPC builds the server with the interceptors every service shares;
+// the interceptors modules add for their own services run last
+func initRPC(cfg config.RPCConfig, logger zerolog.Logger, interceptors *rpc.Interceptors) *grpc.Server {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		otelgrpc.UnaryServerInterceptor(),
+		metrics.UnaryServerInterceptor(),
+		rpc.Recovery(logger),
+		rpc.Logging(logger),
+		rpc.Deadline(cfg.Timeout),
+		interceptors.Unary(),
+	))
 	reflection.Register(server)
 
 	return server
 }
 
+// initHealth builds the monitor with the checks every module depends on;
+// modules add their own checks during startup
+func initHealth(db *sql.DB, nc *nats.Conn) *health.Monitor {
+	monitor := health.NewMonitor(pb.StoresService_ServiceDesc.ServiceName)
+	monitor.AddCheck("postgres", db.PingContext)
+	monitor.AddCheck("nats", func(context.Context) error {
+		if status := nc.Status(); status != nats.CONNECTED {
+			return fmt.Errorf("the connection is %s", status)
+		}
+		return nil
+	})
+
+	return monitor
+}
+
 func initMux(_ config.WebConfig) *chi.Mux {
-	return chi.NewMux()
+	mux := chi.NewMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	return mux
 }
 
 func initJetStream(cfg config.NatsConfig, nc *nats.Conn) (nats.JetStreamContext, error) {
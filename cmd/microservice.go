@@ -5,9 +5,12 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/v8tix/mallbots-stores/internal/config"
+	"github.com/v8tix/mallbots-stores/internal/health"
 	"github.com/v8tix/mallbots-stores/internal/ms"
+	"github.com/v8tix/mallbots-stores/internal/rpc"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -20,15 +23,31 @@ import (
 )
 
 type app struct {
-	cfg     config.AppConfig
-	db      *sql.DB
-	nc      *nats.Conn
-	js      nats.JetStreamContext
-	logger  zerolog.Logger
-	modules []ms.Module
-	mux     *chi.Mux
-	rpc     *grpc.Server
-	waiter  waiter.Waiter
+	adminMux     *chi.Mux
+	cfg          config.AppConfig
+	db           *sql.DB
+	drains       []ms.DrainFunc
+	health       *health.Monitor
+	interceptors *rpc.Interceptors
+	nc           *nats.Conn
+	js           nats.JetStreamContext
+	logger       zerolog.Logger
+	modules      []ms.Module
+	mux          *chi.Mux
+	rpc          *grpc.Server
+	waiter       waiter.Waiter
+	// closed once the servers have stopped taking requests
+	webStopped   chan struct{}
+	rpcStopped   chan struct{}
+	adminStopped chan struct{}
+}
+
+func (a *app) AddDrain(fns ...ms.DrainFunc) {
+	a.drains = append(a.drains, fns...)
+}
+
+func (a *app) AdminMux() *chi.Mux {
+	return a.adminMux
 }
 
 func (a *app) Config() config.AppConfig {
@@ -39,6 +58,14 @@ func (a *app) DB() *sql.DB {
 	return a.db
 }
 
+func (a *app) Health() *health.Monitor {
+	return a.health
+}
+
+func (a *app) Interceptors() *rpc.Interceptors {
+	return a.interceptors
+}
+
 func (a *app) JS() nats.JetStreamContext {
 	return a.js
 }
@@ -70,15 +97,29 @@ func (a *app) startupModules() error {
 }
 
 func (a *app) waitForWeb(ctx context.Context) error {
+	defer close(a.webStopped)
+
+	return a.serveHTTP(ctx, "web", a.cfg.Web.Address(), a.mux)
+}
+
+// waitForAdmin serves the admin API on its own address so that it is never
+// exposed along with the public web server
+func (a *app) waitForAdmin(ctx context.Context) error {
+	defer close(a.adminStopped)
+
+	return a.serveHTTP(ctx, "admin", a.cfg.Admin.Address(), a.adminMux)
+}
+
+func (a *app) serveHTTP(ctx context.Context, name, address string, handler http.Handler) error {
 	webServer := http.Server{
-		Addr:    a.cfg.Web.Address(),
-		Handler: a.mux,
+		Addr:    address,
+		Handler: handler,
 	}
 
 	group, gCtx := errgroup.WithContext(ctx)
 	group.Go(func() error {
-		fmt.Printf("web server started; listening at http://%s\n", a.cfg.Web.Address())
-		defer fmt.Println("web server shutdown")
+		fmt.Printf("%s server started; listening at http://%s\n", name, address)
+		defer fmt.Printf("%s server shutdown\n", name)
 		if err := webServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			return err
 		}
@@ -86,7 +127,7 @@ func (a *app) waitForWeb(ctx context.Context) error {
 	})
 	group.Go(func() error {
 		<-gCtx.Done()
-		fmt.Println("web server to be shutdown")
+		fmt.Printf("%s server to be shutdown\n", name)
 		ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
 		defer cancel()
 		if err := webServer.Shutdown(ctx); err != nil {
@@ -99,6 +140,8 @@ func (a *app) waitForWeb(ctx context.Context) error {
 }
 
 func (a *app) waitForRPC(ctx context.Context) error {
+	defer close(a.rpcStopped)
+
 	listener, err := net.Listen("tcp", a.cfg.RPC.Address())
 	if err != nil {
 		return err
@@ -149,7 +192,32 @@ func (a *app) waitForStream(ctx context.Context) error {
 	})
 	group.Go(func() error {
 		<-gCtx.Done()
+		// nothing may publish once the stream is drained, so the servers are
+		// stopped and the drains are finished first
+		<-a.webStopped
+		<-a.adminStopped
+		<-a.rpcStopped
+		a.drain()
 		return a.nc.Drain()
 	})
 	return group.Wait()
 }
+
+// drain runs the drains concurrently, together bounded by ShutdownTimeout
+func (a *app) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, fn := range a.drains {
+		fn := fn
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(ctx); err != nil {
+				a.logger.Error().Err(err).Msg("failed to drain before shutdown")
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/v8tix/mallbots-stores"
+	"github.com/v8tix/mallbots-stores/internal/config"
+)
+
+// rebuild replays the event store into the named read models, or into every
+// read model when none are named, without starting the servers
+func rebuild(configFile string, cfg *config.AppConfig, projections []string) (err error) {
+	err = config.InitConfig(configFile, cfg)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open("pgx", cfg.PG.Conn)
+	if err != nil {
+		return err
+	}
+	defer func(db *sql.DB) {
+		err := db.Close()
+		if err != nil {
+			return
+		}
+	}(db)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err = stores.RebuildProjections(ctx, db, initLogger(cfg), projections...); err != nil {
+		return err
+	}
+
+	fmt.Println("rebuilt mallbots stores projections")
+
+	return nil
+}
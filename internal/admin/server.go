@@ -0,0 +1,410 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stackus/errors"
+
+	"github.com/v8tix/eda/di"
+	"github.com/v8tix/mallbots-stores/internal/application"
+	"github.com/v8tix/mallbots-stores/internal/application/queries"
+	"github.com/v8tix/mallbots-stores/internal/domain"
+	"github.com/v8tix/mallbots-stores/internal/outbox"
+	"github.com/v8tix/mallbots-stores/internal/postgres"
+	"github.com/v8tix/mallbots-stores/internal/projections"
+	"github.com/v8tix/mallbots-stores/internal/replay"
+)
+
+const (
+	apiRoot = "/admin/stores"
+
+	defaultDeadLetterLimit = 50
+	maxDeadLetterLimit     = 500
+)
+
+// server exposes operational endpoints that are not part of the public
+// stores API
+type server struct {
+	c di.Container
+}
+
+// RegisterServer mounts the admin API on the admin mux; when token is not
+// empty every request must carry it as a bearer token
+func RegisterServer(container di.Container, mux *chi.Mux, token string) error {
+	s := server{c: container}
+
+	router := chi.NewRouter()
+	if token != "" {
+		router.Use(requireToken(token))
+	}
+	router.Get("/projections", s.getProjections)
+	router.Post("/projections/{projection}/rebuild", s.rebuildProjection)
+	router.Get("/projections/rebuilds/{id}", s.getRebuild)
+	router.Delete("/snapshots/{aggregate}", s.invalidateSnapshots)
+	router.Post("/snapshots/{aggregate}/regenerate", s.regenerateSnapshots)
+	router.Post("/replays", s.replayEvents)
+	router.Get("/replays/{id}", s.getReplay)
+	router.Get("/outbox/health", s.getOutboxHealth)
+	router.Get("/outbox/dead-letters", s.getDeadLetters)
+	router.Post("/outbox/dead-letters/requeue", s.requeueDeadLetters)
+	router.Post("/outbox/dead-letters/{id}/requeue", s.requeueDeadLetters)
+	router.Get("/stores/{id}/history", s.getStoreHistory)
+	router.Get("/products/{id}/history", s.getProductHistory)
+
+	mux.Mount(apiRoot, router)
+
+	return nil
+}
+
+func (s server) getProjections(w http.ResponseWriter, _ *http.Request) {
+	rebuilder := s.c.Get("rebuilder").(projections.Rebuilder)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"projections": rebuilder.Names(),
+	})
+}
+
+// rebuildProjection starts rebuilding the projection in the background; the
+// progress of the returned job is polled with getRebuild
+func (s server) rebuildProjection(w http.ResponseWriter, r *http.Request) {
+	jobs := s.c.Get("rebuildJobs").(*projections.RebuildJobs)
+
+	job, err := jobs.Start(chi.URLParam(r, "projection"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/projections/rebuilds/%s", apiRoot, job.ID))
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s server) getRebuild(w http.ResponseWriter, r *http.Request) {
+	jobs := s.c.Get("rebuildJobs").(*projections.RebuildJobs)
+
+	job, err := jobs.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s server) invalidateSnapshots(w http.ResponseWriter, r *http.Request) {
+	regenerator := s.c.Get("snapshotRegenerator").(postgres.SnapshotRegenerator)
+	aggregateName := chi.URLParam(r, "aggregate")
+
+	invalidated, err := regenerator.Invalidate(r.Context(), aggregateName)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"aggregate":   aggregateName,
+		"invalidated": invalidated,
+	})
+}
+
+func (s server) regenerateSnapshots(w http.ResponseWriter, r *http.Request) {
+	regenerator := s.c.Get("snapshotRegenerator").(postgres.SnapshotRegenerator)
+	aggregateName := chi.URLParam(r, "aggregate")
+
+	regenerated, err := regenerator.Regenerate(r.Context(), aggregateName)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"aggregate":   aggregateName,
+		"regenerated": regenerated,
+	})
+}
+
+// replayEvents starts republishing the integration events of stored events
+// in the background; the progress of the returned job is polled with
+// getReplay. The request body selects the events:
+//
+//	{"name": "search-backfill", "aggregates": ["stores.Product"], "ids": [],
+//	 "subject": "", "rate": 500, "restart": false}
+//
+// An interrupted replay resumes when it is requested again under its name.
+func (s server) replayEvents(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name       string   `json:"name"`
+		Aggregates []string `json:"aggregates"`
+		IDs        []string `json:"ids"`
+		Subject    string   `json:"subject"`
+		Rate       int      `json:"rate"`
+		Restart    bool     `json:"restart"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, errors.ErrBadRequest.Msg("the replay request is not valid JSON"))
+		return
+	}
+	if request.Rate < 0 {
+		writeError(w, errors.ErrBadRequest.Msg("rate cannot be negative"))
+		return
+	}
+
+	jobs := s.c.Get("replayJobs").(*replay.ReplayJobs)
+
+	job, err := jobs.Start(replay.Request{
+		Name:           request.Name,
+		AggregateNames: request.Aggregates,
+		AggregateIDs:   request.IDs,
+		Subject:        request.Subject,
+		Rate:           request.Rate,
+		Restart:        request.Restart,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/replays/%s", apiRoot, job.ID))
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s server) getReplay(w http.ResponseWriter, r *http.Request) {
+	jobs := s.c.Get("replayJobs").(*replay.ReplayJobs)
+
+	job, err := jobs.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
+
+// getOutboxHealth reports the state of the outbox processor; the status is
+// 503 whenever it is not running
+func (s server) getOutboxHealth(w http.ResponseWriter, _ *http.Request) {
+	status := s.c.Get("outboxSupervisor").(*outbox.Supervisor).Status()
+
+	code := http.StatusOK
+	if status.State != outbox.StateRunning {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, code, status)
+}
+
+func (s server) getDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDeadLetterLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxDeadLetterLimit {
+			writeError(w, errors.ErrBadRequest.Msgf("limit must be a number from 1 to %d", maxDeadLetterLimit))
+			return
+		}
+	}
+
+	deadLetters, err := s.c.Get("outboxStore").(postgres.OutboxStore).FindDeadLetters(r.Context(), limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deadLettersFromDomain(deadLetters))
+}
+
+// requeueDeadLetters requeues a single dead-lettered message, or all of them
+// when no id is given
+func (s server) requeueDeadLetters(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	if id := chi.URLParam(r, "id"); id != "" {
+		ids = append(ids, id)
+	}
+
+	requeued, err := s.c.Get("outboxStore").(postgres.OutboxStore).Requeue(r.Context(), ids...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"requeued": requeued,
+	})
+}
+
+func (s server) getStoreHistory(w http.ResponseWriter, r *http.Request) {
+	afterVersion, limit, err := historyParams(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var events []*domain.HistoryEvent
+	err = s.scoped(r.Context(), func(ctx context.Context) (err error) {
+		events, err = di.Get(ctx, "app").(application.App).GetStoreHistory(ctx, queries.GetStoreHistory{
+			ID:           chi.URLParam(r, "id"),
+			AfterVersion: afterVersion,
+			Limit:        limit,
+		})
+		return err
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, historyFromDomain(events))
+}
+
+func (s server) getProductHistory(w http.ResponseWriter, r *http.Request) {
+	afterVersion, limit, err := historyParams(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var events []*domain.HistoryEvent
+	err = s.scoped(r.Context(), func(ctx context.Context) (err error) {
+		events, err = di.Get(ctx, "app").(application.App).GetProductHistory(ctx, queries.GetProductHistory{
+			ID:           chi.URLParam(r, "id"),
+			AfterVersion: afterVersion,
+			Limit:        limit,
+		})
+		return err
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, historyFromDomain(events))
+}
+
+// scoped runs fn with a scoped container, committing the scoped transaction
+// when fn succeeds
+func (s server) scoped(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	ctx = s.c.Scoped(ctx)
+	tx := di.Get(ctx, "tx").(*sql.Tx)
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	return fn(ctx)
+}
+
+func historyParams(r *http.Request) (afterVersion, limit int, err error) {
+	if value := r.URL.Query().Get("after"); value != "" {
+		if afterVersion, err = strconv.Atoi(value); err != nil || afterVersion < 0 {
+			return 0, 0, errors.ErrBadRequest.Msg("after must be a version number")
+		}
+	}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			return 0, 0, errors.ErrBadRequest.Msg("limit must be a positive number")
+		}
+	}
+
+	return afterVersion, limit, nil
+}
+
+func historyFromDomain(events []*domain.HistoryEvent) map[string]any {
+	type historyEvent struct {
+		ID         string         `json:"id"`
+		Name       string         `json:"name"`
+		Version    int            `json:"version"`
+		OccurredAt time.Time      `json:"occurred_at"`
+		Payload    any            `json:"payload"`
+		Metadata   map[string]any `json:"metadata"`
+	}
+
+	history := make([]historyEvent, len(events))
+	for i, event := range events {
+		history[i] = historyEvent{
+			ID:         event.ID,
+			Name:       event.Name,
+			Version:    event.Version,
+			OccurredAt: event.OccurredAt,
+			Payload:    event.Payload,
+			Metadata:   event.Metadata,
+		}
+	}
+
+	return map[string]any{
+		"events": history,
+	}
+}
+
+func deadLettersFromDomain(deadLetters []*postgres.DeadLetter) map[string]any {
+	type deadLetter struct {
+		ID             string    `json:"id"`
+		Name           string    `json:"name"`
+		Subject        string    `json:"subject"`
+		Attempts       int       `json:"attempts"`
+		LastError      string    `json:"last_error"`
+		DeadLetteredAt time.Time `json:"dead_lettered_at"`
+	}
+
+	messages := make([]deadLetter, len(deadLetters))
+	for i, msg := range deadLetters {
+		messages[i] = deadLetter{
+			ID:             msg.ID,
+			Name:           msg.Name,
+			Subject:        msg.Subject,
+			Attempts:       msg.Attempts,
+			LastError:      msg.LastError,
+			DeadLetteredAt: msg.DeadLetteredAt,
+		}
+	}
+
+	return map[string]any{
+		"messages": messages,
+	}
+}
+
+// requireToken rejects requests that do not carry the bearer token
+func requireToken(token string) func(http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+				writeError(w, errors.ErrUnauthorized.Msg("a valid admin token is required"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := errors.HTTPCode(err)
+	if status == http.StatusNotExtended {
+		// uncoded errors
+		status = http.StatusInternalServerError
+	}
+
+	writeJSON(w, status, map[string]any{
+		"code":    errors.TypeCode(err),
+		"message": err.Error(),
+	})
+}
@@ -14,15 +14,15 @@ type (
 		Queries
 	}
 	Commands interface {
-		CreateStore(ctx context.Context, cmd commands.CreateStore) error
-		EnableParticipation(ctx context.Context, cmd commands.EnableParticipation) error
-		DisableParticipation(ctx context.Context, cmd commands.DisableParticipation) error
-		RebrandStore(ctx context.Context, cmd commands.RebrandStore) error
-		AddProduct(ctx context.Context, cmd commands.AddProduct) error
-		RebrandProduct(ctx context.Context, cmd commands.RebrandProduct) error
-		IncreaseProductPrice(ctx context.Context, cmd commands.IncreaseProductPrice) error
-		DecreaseProductPrice(ctx context.Context, cmd commands.DecreaseProductPrice) error
-		RemoveProduct(ctx context.Context, cmd commands.RemoveProduct) error
+		CreateStore(ctx context.Context, cmd commands.CreateStore) (int, error)
+		EnableParticipation(ctx context.Context, cmd commands.EnableParticipation) (int, error)
+		DisableParticipation(ctx context.Context, cmd commands.DisableParticipation) (int, error)
+		RebrandStore(ctx context.Context, cmd commands.RebrandStore) (int, error)
+		AddProduct(ctx context.Context, cmd commands.AddProduct) (int, error)
+		RebrandProduct(ctx context.Context, cmd commands.RebrandProduct) (int, error)
+		IncreaseProductPrice(ctx context.Context, cmd commands.IncreaseProductPrice) (int, error)
+		DecreaseProductPrice(ctx context.Context, cmd commands.DecreaseProductPrice) (int, error)
+		RemoveProduct(ctx context.Context, cmd commands.RemoveProduct) (int, error)
 	}
 	Queries interface {
 		GetStore(ctx context.Context, query queries.GetStore) (*domain.MallStore, error)
@@ -30,6 +30,8 @@ type (
 		GetParticipatingStores(ctx context.Context, query queries.GetParticipatingStores) ([]*domain.MallStore, error)
 		GetCatalog(ctx context.Context, query queries.GetCatalog) ([]*domain.CatalogProduct, error)
 		GetProduct(ctx context.Context, query queries.GetProduct) (*domain.CatalogProduct, error)
+		GetStoreHistory(ctx context.Context, query queries.GetStoreHistory) ([]*domain.HistoryEvent, error)
+		GetProductHistory(ctx context.Context, query queries.GetProductHistory) ([]*domain.HistoryEvent, error)
 	}
 
 	Application struct {
@@ -53,13 +55,15 @@ type (
 		queries.GetParticipatingStoresHandler
 		queries.GetCatalogHandler
 		queries.GetProductHandler
+		queries.GetStoreHistoryHandler
+		queries.GetProductHistoryHandler
 	}
 )
 
 var _ App = (*Application)(nil)
 
 func New(stores domain.StoreRepository, products domain.ProductRepository,
-	catalog domain.CatalogRepository, mall domain.MallRepository,
+	catalog domain.CatalogRepository, mall domain.MallRepository, history domain.HistoryRepository,
 ) *Application {
 	return &Application{
 		appCommands: appCommands{
@@ -74,11 +78,13 @@ func New(stores domain.StoreRepository, products domain.ProductRepository,
 			RemoveProductHandler:        commands.NewRemoveProductHandler(products),
 		},
 		appQueries: appQueries{
-			GetStoreHandler:               queries.NewGetStoreHandler(mall),
+			GetStoreHandler:               queries.NewGetStoreHandler(mall, history),
 			GetStoresHandler:              queries.NewGetStoresHandler(mall),
 			GetParticipatingStoresHandler: queries.NewGetParticipatingStoresHandler(mall),
 			GetCatalogHandler:             queries.NewGetCatalogHandler(catalog),
-			GetProductHandler:             queries.NewGetProductHandler(catalog),
+			GetProductHandler:             queries.NewGetProductHandler(catalog, history),
+			GetStoreHistoryHandler:        queries.NewGetStoreHistoryHandler(history),
+			GetProductHistoryHandler:      queries.NewGetProductHistoryHandler(history),
 		},
 	}
 }
@@ -27,11 +27,15 @@ func NewAddProductHandler(products domain.ProductRepository) AddProductHandler {
 	}
 }
 
-func (h AddProductHandler) AddProduct(ctx context.Context, cmd AddProduct) error {
+func (h AddProductHandler) AddProduct(ctx context.Context, cmd AddProduct) (int, error) {
 	product, err := domain.CreateProduct(cmd.ID, cmd.StoreID, cmd.Name, cmd.Description, cmd.SKU, cmd.Price)
 	if err != nil {
-		return errors.Wrap(err, "error adding product")
+		return 0, errors.Wrap(err, "error adding product")
 	}
 
-	return errors.Wrap(h.products.Save(ctx, product), "error adding product")
+	if err = h.products.Save(ctx, product); err != nil {
+		return 0, errors.Wrap(err, "error adding product")
+	}
+
+	return product.Version(), nil
 }
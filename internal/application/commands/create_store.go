@@ -24,11 +24,15 @@ func NewCreateStoreHandler(stores domain.StoreRepository) CreateStoreHandler {
 	}
 }
 
-func (h CreateStoreHandler) CreateStore(ctx context.Context, cmd CreateStore) error {
+func (h CreateStoreHandler) CreateStore(ctx context.Context, cmd CreateStore) (int, error) {
 	store, err := domain.CreateStore(cmd.ID, cmd.Name, cmd.Location)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return h.stores.Save(ctx, store)
+	if err = h.stores.Save(ctx, store); err != nil {
+		return 0, err
+	}
+
+	return store.Version(), nil
 }
@@ -7,8 +7,9 @@ import (
 )
 
 type DecreaseProductPrice struct {
-	ID    string
-	Price float64
+	ID              string
+	Price           float64
+	ExpectedVersion int
 }
 
 type DecreaseProductPriceHandler struct {
@@ -19,15 +20,27 @@ func NewDecreaseProductPriceHandler(products domain.ProductRepository) DecreaseP
 	return DecreaseProductPriceHandler{products: products}
 }
 
-func (h DecreaseProductPriceHandler) DecreaseProductPrice(ctx context.Context, cmd DecreaseProductPrice) error {
+func (h DecreaseProductPriceHandler) DecreaseProductPrice(ctx context.Context, cmd DecreaseProductPrice) (int, error) {
 	product, err := h.products.Load(ctx, cmd.ID)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	if product.Version() == 0 {
+		return 0, domain.ErrProductNotFound
+	}
+
+	if err = checkExpectedVersion(product, cmd.ExpectedVersion); err != nil {
+		return 0, err
 	}
 
 	if err = product.DecreasePrice(cmd.Price); err != nil {
-		return err
+		return 0, err
+	}
+
+	if err = h.products.Save(ctx, product); err != nil {
+		return 0, err
 	}
 
-	return h.products.Save(ctx, product)
+	return product.Version(), nil
 }
@@ -7,7 +7,8 @@ import (
 )
 
 type DisableParticipation struct {
-	ID string
+	ID              string
+	ExpectedVersion int
 }
 
 type DisableParticipationHandler struct {
@@ -20,15 +21,27 @@ func NewDisableParticipationHandler(stores domain.StoreRepository) DisablePartic
 	}
 }
 
-func (h DisableParticipationHandler) DisableParticipation(ctx context.Context, cmd DisableParticipation) error {
+func (h DisableParticipationHandler) DisableParticipation(ctx context.Context, cmd DisableParticipation) (int, error) {
 	store, err := h.stores.Load(ctx, cmd.ID)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	if store.Version() == 0 {
+		return 0, domain.ErrStoreNotFound
+	}
+
+	if err = checkExpectedVersion(store, cmd.ExpectedVersion); err != nil {
+		return 0, err
 	}
 
 	if err = store.DisableParticipation(); err != nil {
-		return err
+		return 0, err
+	}
+
+	if err = h.stores.Save(ctx, store); err != nil {
+		return 0, err
 	}
 
-	return h.stores.Save(ctx, store)
+	return store.Version(), nil
 }
@@ -7,7 +7,8 @@ import (
 )
 
 type EnableParticipation struct {
-	ID string
+	ID              string
+	ExpectedVersion int
 }
 
 type EnableParticipationHandler struct {
@@ -20,15 +21,27 @@ func NewEnableParticipationHandler(stores domain.StoreRepository) EnableParticip
 	}
 }
 
-func (h EnableParticipationHandler) EnableParticipation(ctx context.Context, cmd EnableParticipation) error {
+func (h EnableParticipationHandler) EnableParticipation(ctx context.Context, cmd EnableParticipation) (int, error) {
 	store, err := h.stores.Load(ctx, cmd.ID)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	if store.Version() == 0 {
+		return 0, domain.ErrStoreNotFound
+	}
+
+	if err = checkExpectedVersion(store, cmd.ExpectedVersion); err != nil {
+		return 0, err
 	}
 
 	if err = store.EnableParticipation(); err != nil {
-		return err
+		return 0, err
+	}
+
+	if err = h.stores.Save(ctx, store); err != nil {
+		return 0, err
 	}
 
-	return h.stores.Save(ctx, store)
+	return store.Version(), nil
 }
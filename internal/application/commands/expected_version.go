@@ -0,0 +1,19 @@
+package commands
+
+import (
+	"github.com/stackus/errors"
+
+	"github.com/v8tix/eda/es"
+)
+
+var ErrVersionConflict = errors.Wrap(errors.ErrAborted, "the aggregate has changed since the expected version")
+
+// checkExpectedVersion guards against lost updates; an expected version of
+// zero means the caller did not ask for a check
+func checkExpectedVersion(aggregate es.Versioner, expectedVersion int) error {
+	if expectedVersion != 0 && aggregate.Version() != expectedVersion {
+		return ErrVersionConflict
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stackus/errors"
+
+	"github.com/v8tix/mallbots-stores/internal/domain"
+)
+
+// fakeStores loads stores without history at version zero, like the event
+// sourced repository does
+type fakeStores struct {
+	stores map[string]*domain.Store
+	saved  bool
+}
+
+func (r *fakeStores) Load(_ context.Context, storeID string) (*domain.Store, error) {
+	if store, exists := r.stores[storeID]; exists {
+		return store, nil
+	}
+	return domain.NewStore(storeID), nil
+}
+
+func (r *fakeStores) Save(_ context.Context, store *domain.Store) error {
+	r.saved = true
+	store.CommitEvents()
+	return nil
+}
+
+type fakeProducts struct {
+	products map[string]*domain.Product
+	saved    bool
+}
+
+func (r *fakeProducts) Load(_ context.Context, id string) (*domain.Product, error) {
+	if product, exists := r.products[id]; exists {
+		return product, nil
+	}
+	return domain.NewProduct(id), nil
+}
+
+func (r *fakeProducts) Save(_ context.Context, product *domain.Product) error {
+	r.saved = true
+	product.CommitEvents()
+	return nil
+}
+
+// storeAtVersion2 returns a store that was created and then rebranded
+func storeAtVersion2(t *testing.T) *domain.Store {
+	t.Helper()
+
+	store, err := domain.CreateStore("store-1", "Store", "Mall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.CommitEvents()
+	if err = store.Rebrand("Rebranded"); err != nil {
+		t.Fatal(err)
+	}
+	store.CommitEvents()
+
+	return store
+}
+
+// productAtVersion2 returns a product that was added and then rebranded
+func productAtVersion2(t *testing.T) *domain.Product {
+	t.Helper()
+
+	product, err := domain.CreateProduct("product-1", "store-1", "Product", "", "SKU", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	product.CommitEvents()
+	if err = product.Rebrand("Rebranded", ""); err != nil {
+		t.Fatal(err)
+	}
+	product.CommitEvents()
+
+	return product
+}
+
+func TestExpectedVersion(t *testing.T) {
+	type command func(ctx context.Context, stores *fakeStores, products *fakeProducts) (int, error)
+
+	rebrandStore := func(expectedVersion int) command {
+		return func(ctx context.Context, stores *fakeStores, _ *fakeProducts) (int, error) {
+			return NewRebrandStoreHandler(stores).RebrandStore(ctx, RebrandStore{
+				ID:              "store-1",
+				Name:            "Store",
+				ExpectedVersion: expectedVersion,
+			})
+		}
+	}
+	increasePrice := func(expectedVersion int) command {
+		return func(ctx context.Context, _ *fakeStores, products *fakeProducts) (int, error) {
+			return NewIncreaseProductPriceHandler(products).IncreaseProductPrice(ctx, IncreaseProductPrice{
+				ID:              "product-1",
+				Price:           20,
+				ExpectedVersion: expectedVersion,
+			})
+		}
+	}
+
+	tests := map[string]struct {
+		command     command
+		wantVersion int
+		wantErr     error
+	}{
+		"store without expected version": {
+			command:     rebrandStore(0),
+			wantVersion: 3,
+		},
+		"store at the expected version": {
+			command:     rebrandStore(2),
+			wantVersion: 3,
+		},
+		"store changed since the expected version": {
+			command: rebrandStore(1),
+			wantErr: ErrVersionConflict,
+		},
+		"store behind the expected version": {
+			command: rebrandStore(3),
+			wantErr: ErrVersionConflict,
+		},
+		"product at the expected version": {
+			command:     increasePrice(2),
+			wantVersion: 3,
+		},
+		"product changed since the expected version": {
+			command: increasePrice(1),
+			wantErr: ErrVersionConflict,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			stores := &fakeStores{stores: map[string]*domain.Store{"store-1": storeAtVersion2(t)}}
+			products := &fakeProducts{products: map[string]*domain.Product{"product-1": productAtVersion2(t)}}
+
+			version, err := tc.command(context.Background(), stores, products)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tc.wantErr)
+				}
+				if stores.saved || products.saved {
+					t.Error("the aggregate was saved despite the conflict")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != tc.wantVersion {
+				t.Errorf("version = %d, want %d", version, tc.wantVersion)
+			}
+		})
+	}
+}
@@ -7,8 +7,9 @@ import (
 )
 
 type IncreaseProductPrice struct {
-	ID    string
-	Price float64
+	ID              string
+	Price           float64
+	ExpectedVersion int
 }
 
 type IncreaseProductPriceHandler struct {
@@ -19,15 +20,27 @@ func NewIncreaseProductPriceHandler(products domain.ProductRepository) IncreaseP
 	return IncreaseProductPriceHandler{products: products}
 }
 
-func (h IncreaseProductPriceHandler) IncreaseProductPrice(ctx context.Context, cmd IncreaseProductPrice) error {
+func (h IncreaseProductPriceHandler) IncreaseProductPrice(ctx context.Context, cmd IncreaseProductPrice) (int, error) {
 	product, err := h.products.Load(ctx, cmd.ID)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	if product.Version() == 0 {
+		return 0, domain.ErrProductNotFound
+	}
+
+	if err = checkExpectedVersion(product, cmd.ExpectedVersion); err != nil {
+		return 0, err
 	}
 
 	if err = product.IncreasePrice(cmd.Price); err != nil {
-		return err
+		return 0, err
+	}
+
+	if err = h.products.Save(ctx, product); err != nil {
+		return 0, err
 	}
 
-	return h.products.Save(ctx, product)
+	return product.Version(), nil
 }
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stackus/errors"
+
+	"github.com/v8tix/mallbots-stores/internal/domain"
+)
+
+func TestCommandsOnMissingAggregates(t *testing.T) {
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		command func(stores *fakeStores, products *fakeProducts) (int, error)
+		wantErr error
+	}{
+		"enable participation": {
+			command: func(stores *fakeStores, _ *fakeProducts) (int, error) {
+				return NewEnableParticipationHandler(stores).EnableParticipation(ctx, EnableParticipation{ID: "missing"})
+			},
+			wantErr: domain.ErrStoreNotFound,
+		},
+		"disable participation": {
+			command: func(stores *fakeStores, _ *fakeProducts) (int, error) {
+				return NewDisableParticipationHandler(stores).DisableParticipation(ctx, DisableParticipation{ID: "missing"})
+			},
+			wantErr: domain.ErrStoreNotFound,
+		},
+		"rebrand store": {
+			command: func(stores *fakeStores, _ *fakeProducts) (int, error) {
+				return NewRebrandStoreHandler(stores).RebrandStore(ctx, RebrandStore{ID: "missing", Name: "Store"})
+			},
+			wantErr: domain.ErrStoreNotFound,
+		},
+		"rebrand product": {
+			command: func(_ *fakeStores, products *fakeProducts) (int, error) {
+				return NewRebrandProductHandler(products).RebrandProduct(ctx, RebrandProduct{ID: "missing", Name: "Product"})
+			},
+			wantErr: domain.ErrProductNotFound,
+		},
+		"increase product price": {
+			command: func(_ *fakeStores, products *fakeProducts) (int, error) {
+				return NewIncreaseProductPriceHandler(products).IncreaseProductPrice(ctx, IncreaseProductPrice{ID: "missing", Price: 20})
+			},
+			wantErr: domain.ErrProductNotFound,
+		},
+		"decrease product price": {
+			command: func(_ *fakeStores, products *fakeProducts) (int, error) {
+				return NewDecreaseProductPriceHandler(products).DecreaseProductPrice(ctx, DecreaseProductPrice{ID: "missing", Price: 5})
+			},
+			wantErr: domain.ErrProductNotFound,
+		},
+		"remove product": {
+			command: func(_ *fakeStores, products *fakeProducts) (int, error) {
+				return NewRemoveProductHandler(products).RemoveProduct(ctx, RemoveProduct{ID: "missing"})
+			},
+			wantErr: domain.ErrProductNotFound,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			stores := &fakeStores{}
+			products := &fakeProducts{}
+
+			_, err := tc.command(stores, products)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			if stores.saved || products.saved {
+				t.Error("a missing aggregate was saved")
+			}
+		})
+	}
+}
@@ -7,9 +7,10 @@ import (
 )
 
 type RebrandProduct struct {
-	ID          string
-	Name        string
-	Description string
+	ID              string
+	Name            string
+	Description     string
+	ExpectedVersion int
 }
 
 type RebrandProductHandler struct {
@@ -22,15 +23,27 @@ func NewRebrandProductHandler(products domain.ProductRepository) RebrandProductH
 	}
 }
 
-func (h RebrandProductHandler) RebrandProduct(ctx context.Context, cmd RebrandProduct) error {
+func (h RebrandProductHandler) RebrandProduct(ctx context.Context, cmd RebrandProduct) (int, error) {
 	product, err := h.products.Load(ctx, cmd.ID)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	if product.Version() == 0 {
+		return 0, domain.ErrProductNotFound
+	}
+
+	if err = checkExpectedVersion(product, cmd.ExpectedVersion); err != nil {
+		return 0, err
 	}
 
 	if err = product.Rebrand(cmd.Name, cmd.Description); err != nil {
-		return err
+		return 0, err
+	}
+
+	if err = h.products.Save(ctx, product); err != nil {
+		return 0, err
 	}
 
-	return h.products.Save(ctx, product)
+	return product.Version(), nil
 }
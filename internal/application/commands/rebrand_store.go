@@ -7,8 +7,9 @@ import (
 )
 
 type RebrandStore struct {
-	ID   string
-	Name string
+	ID              string
+	Name            string
+	ExpectedVersion int
 }
 
 type RebrandStoreHandler struct {
@@ -21,15 +22,27 @@ func NewRebrandStoreHandler(stores domain.StoreRepository) RebrandStoreHandler {
 	}
 }
 
-func (h RebrandStoreHandler) RebrandStore(ctx context.Context, cmd RebrandStore) error {
+func (h RebrandStoreHandler) RebrandStore(ctx context.Context, cmd RebrandStore) (int, error) {
 	store, err := h.stores.Load(ctx, cmd.ID)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	if store.Version() == 0 {
+		return 0, domain.ErrStoreNotFound
+	}
+
+	if err = checkExpectedVersion(store, cmd.ExpectedVersion); err != nil {
+		return 0, err
 	}
 
 	if err = store.Rebrand(cmd.Name); err != nil {
-		return err
+		return 0, err
+	}
+
+	if err = h.stores.Save(ctx, store); err != nil {
+		return 0, err
 	}
 
-	return h.stores.Save(ctx, store)
+	return store.Version(), nil
 }
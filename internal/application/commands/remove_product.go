@@ -7,7 +7,8 @@ import (
 )
 
 type RemoveProduct struct {
-	ID string
+	ID              string
+	ExpectedVersion int
 }
 
 type RemoveProductHandler struct {
@@ -20,15 +21,27 @@ func NewRemoveProductHandler(products domain.ProductRepository) RemoveProductHan
 	}
 }
 
-func (h RemoveProductHandler) RemoveProduct(ctx context.Context, cmd RemoveProduct) error {
+func (h RemoveProductHandler) RemoveProduct(ctx context.Context, cmd RemoveProduct) (int, error) {
 	product, err := h.products.Load(ctx, cmd.ID)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	if product.Version() == 0 {
+		return 0, domain.ErrProductNotFound
+	}
+
+	if err = checkExpectedVersion(product, cmd.ExpectedVersion); err != nil {
+		return 0, err
 	}
 
 	if err = product.Remove(); err != nil {
-		return err
+		return 0, err
+	}
+
+	if err = h.products.Save(ctx, product); err != nil {
+		return 0, err
 	}
 
-	return h.products.Save(ctx, product)
+	return product.Version(), nil
 }
@@ -2,22 +2,65 @@ package queries
 
 import (
 	"context"
+	"time"
 
 	"github.com/v8tix/mallbots-stores/internal/domain"
 )
 
+// GetProduct returns the current product, or when AsOf or AsOfVersion are
+// set, the product as it was at that time or version
 type GetProduct struct {
-	ID string
+	ID          string
+	AsOf        time.Time
+	AsOfVersion int
 }
 
 type GetProductHandler struct {
 	catalog domain.CatalogRepository
+	history domain.HistoryRepository
 }
 
-func NewGetProductHandler(catalog domain.CatalogRepository) GetProductHandler {
-	return GetProductHandler{catalog: catalog}
+func NewGetProductHandler(catalog domain.CatalogRepository, history domain.HistoryRepository) GetProductHandler {
+	return GetProductHandler{catalog: catalog, history: history}
 }
 
 func (h GetProductHandler) GetProduct(ctx context.Context, query GetProduct) (*domain.CatalogProduct, error) {
-	return h.catalog.Find(ctx, query.ID)
+	if !query.AsOf.IsZero() || query.AsOfVersion != 0 {
+		return h.getProductAsOf(ctx, query)
+	}
+
+	product, err := h.catalog.Find(ctx, query.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the projection does not track versions; the event stream is the
+	// authority, and its latest version is read without loading the product
+	product.Version, err = h.history.Version(ctx, domain.ProductAggregate, query.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+func (h GetProductHandler) getProductAsOf(ctx context.Context, query GetProduct) (*domain.CatalogProduct, error) {
+	product := domain.NewProduct(query.ID)
+	if err := h.history.LoadAsOf(ctx, product, query.AsOf, query.AsOfVersion); err != nil {
+		return nil, err
+	}
+
+	if product.Version() == 0 {
+		return nil, domain.ErrProductNotFound
+	}
+
+	return &domain.CatalogProduct{
+		ID:          product.ID(),
+		StoreID:     product.StoreID,
+		Name:        product.Name,
+		Description: product.Description,
+		SKU:         product.SKU,
+		Price:       product.Price,
+		Version:     product.Version(),
+	}, nil
 }
@@ -0,0 +1,36 @@
+package queries
+
+import (
+	"context"
+
+	"github.com/v8tix/mallbots-stores/internal/domain"
+)
+
+// GetProductHistory pages through the events of a product in the order they
+// occurred, starting after AfterVersion
+type GetProductHistory struct {
+	ID           string
+	AfterVersion int
+	Limit        int
+}
+
+type GetProductHistoryHandler struct {
+	history domain.HistoryRepository
+}
+
+func NewGetProductHistoryHandler(history domain.HistoryRepository) GetProductHistoryHandler {
+	return GetProductHistoryHandler{history: history}
+}
+
+func (h GetProductHistoryHandler) GetProductHistory(ctx context.Context, query GetProductHistory) ([]*domain.HistoryEvent, error) {
+	events, err := h.history.History(ctx, domain.ProductAggregate, query.ID, query.AfterVersion, historyLimit(query.Limit))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(events) == 0 && query.AfterVersion == 0 {
+		return nil, domain.ErrProductNotFound
+	}
+
+	return events, nil
+}
@@ -2,22 +2,63 @@ package queries
 
 import (
 	"context"
+	"time"
 
 	"github.com/v8tix/mallbots-stores/internal/domain"
 )
 
+// GetStore returns the current store, or when AsOf or AsOfVersion are set,
+// the store as it was at that time or version
 type GetStore struct {
-	ID string
+	ID          string
+	AsOf        time.Time
+	AsOfVersion int
 }
 
 type GetStoreHandler struct {
-	mall domain.MallRepository
+	mall    domain.MallRepository
+	history domain.HistoryRepository
 }
 
-func NewGetStoreHandler(mall domain.MallRepository) GetStoreHandler {
-	return GetStoreHandler{mall: mall}
+func NewGetStoreHandler(mall domain.MallRepository, history domain.HistoryRepository) GetStoreHandler {
+	return GetStoreHandler{mall: mall, history: history}
 }
 
 func (h GetStoreHandler) GetStore(ctx context.Context, query GetStore) (*domain.MallStore, error) {
-	return h.mall.Find(ctx, query.ID)
+	if !query.AsOf.IsZero() || query.AsOfVersion != 0 {
+		return h.getStoreAsOf(ctx, query)
+	}
+
+	store, err := h.mall.Find(ctx, query.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the projection does not track versions; the event stream is the
+	// authority, and its latest version is read without loading the store
+	store.Version, err = h.history.Version(ctx, domain.StoreAggregate, query.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (h GetStoreHandler) getStoreAsOf(ctx context.Context, query GetStore) (*domain.MallStore, error) {
+	store := domain.NewStore(query.ID)
+	if err := h.history.LoadAsOf(ctx, store, query.AsOf, query.AsOfVersion); err != nil {
+		return nil, err
+	}
+
+	if store.Version() == 0 {
+		return nil, domain.ErrStoreNotFound
+	}
+
+	return &domain.MallStore{
+		ID:            store.ID(),
+		Name:          store.Name,
+		Location:      store.Location,
+		Participating: store.Participating,
+		Version:       store.Version(),
+	}, nil
 }
@@ -0,0 +1,36 @@
+package queries
+
+import (
+	"context"
+
+	"github.com/v8tix/mallbots-stores/internal/domain"
+)
+
+// GetStoreHistory pages through the events of a store in the order they
+// occurred, starting after AfterVersion
+type GetStoreHistory struct {
+	ID           string
+	AfterVersion int
+	Limit        int
+}
+
+type GetStoreHistoryHandler struct {
+	history domain.HistoryRepository
+}
+
+func NewGetStoreHistoryHandler(history domain.HistoryRepository) GetStoreHistoryHandler {
+	return GetStoreHistoryHandler{history: history}
+}
+
+func (h GetStoreHistoryHandler) GetStoreHistory(ctx context.Context, query GetStoreHistory) ([]*domain.HistoryEvent, error) {
+	events, err := h.history.History(ctx, domain.StoreAggregate, query.ID, query.AfterVersion, historyLimit(query.Limit))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(events) == 0 && query.AfterVersion == 0 {
+		return nil, domain.ErrStoreNotFound
+	}
+
+	return events, nil
+}
@@ -0,0 +1,17 @@
+package queries
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+func historyLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return defaultHistoryLimit
+	case limit > maxHistoryLimit:
+		return maxHistoryLimit
+	default:
+		return limit
+	}
+}
@@ -20,20 +20,81 @@ type (
 		ClientName string `json:"client_name,omitempty"`
 	}
 
+	// IdempotencyConfig sets how long idempotency keys are honoured, 24 hours
+	// by default; expired keys are purged every PurgeInterval
+	IdempotencyConfig struct {
+		Retention     time.Duration `json:"retention,omitempty"`
+		PurgeInterval time.Duration `json:"purge_interval,omitempty"`
+	}
+
+	// SnapshotConfig selects when an aggregate is snapshotted; Strategy is
+	// one of "events", "interval" or "disabled". AppConfig.Snapshots is keyed
+	// by aggregate name, e.g. "stores.Store"
+	SnapshotConfig struct {
+		Strategy string        `json:"strategy,omitempty"`
+		Events   int           `json:"events,omitempty"`
+		Interval time.Duration `json:"interval,omitempty"`
+	}
+
+	// OutboxConfig tunes the outbox processor; the processor is woken when
+	// messages are saved and polls every PollingInterval as a fallback.
+	// Published messages older than Retention are purged every PurgeInterval;
+	// Purge is one of "delete" (default), "archive" or "disabled".
+	OutboxConfig struct {
+		BatchSize       int           `json:"batch_size,omitempty"`
+		PollingInterval time.Duration `json:"polling_interval,omitempty"`
+		MaxAttempts     int           `json:"max_attempts,omitempty"`
+		Backoff         time.Duration `json:"backoff,omitempty"`
+		MaxBackoff      time.Duration `json:"max_backoff,omitempty"`
+		Purge           string        `json:"purge,omitempty"`
+		Retention       time.Duration `json:"retention,omitempty"`
+		PurgeInterval   time.Duration `json:"purge_interval,omitempty"`
+	}
+
+	// IntegrationConfig controls the optional integration events;
+	// StateTransfer publishes the full state of stores and products after
+	// every change. Encoding is one of "stream" (default),
+	// "cloudevents-binary" or "cloudevents-structured"; CloudEvents are
+	// published with CloudEventsSource as their source
+	IntegrationConfig struct {
+		StateTransfer     bool   `json:"state_transfer,omitempty"`
+		Encoding          string `json:"encoding,omitempty"`
+		CloudEventsSource string `json:"cloudevents_source,omitempty"`
+	}
+
+	// TracingConfig selects where spans are exported; Exporter is one of
+	// "otlp", "stdout" or "disabled" (default). The OTLP exporter sends
+	// spans over gRPC to Endpoint, without TLS when Insecure is set
+	TracingConfig struct {
+		Exporter    string `json:"exporter,omitempty"`
+		Endpoint    string `json:"endpoint,omitempty"`
+		Insecure    bool   `json:"insecure,omitempty"`
+		ServiceName string `json:"service_name,omitempty"`
+	}
+
 	AppConfig struct {
-		Environment     string        `json:"environment,omitempty"`
-		LogLevel        string        `json:"log_level,omitempty"`
-		PG              PGConfig      `json:"db_cfg,omitempty"`
-		Nats            NatsConfig    `json:"nats_cfg,omitempty"`
-		RPC             RPCConfig     `json:"rpc_cfg,omitempty"`
-		Web             WebConfig     `json:"web_cfg,omitempty"`
-		ShutdownTimeout time.Duration `json:"shutdown_timeout,omitempty"`
+		Environment     string                    `json:"environment,omitempty"`
+		LogLevel        string                    `json:"log_level,omitempty"`
+		PG              PGConfig                  `json:"db_cfg,omitempty"`
+		Nats            NatsConfig                `json:"nats_cfg,omitempty"`
+		RPC             RPCConfig                 `json:"rpc_cfg,omitempty"`
+		Web             WebConfig                 `json:"web_cfg,omitempty"`
+		Admin           AdminConfig               `json:"admin_cfg,omitempty"`
+		Idempotency     IdempotencyConfig         `json:"idempotency_cfg,omitempty"`
+		Outbox          OutboxConfig              `json:"outbox_cfg,omitempty"`
+		Integration     IntegrationConfig         `json:"integration_cfg,omitempty"`
+		Tracing         TracingConfig             `json:"tracing_cfg,omitempty"`
+		Snapshots       map[string]SnapshotConfig `json:"snapshots_cfg,omitempty"`
+		ShutdownTimeout time.Duration             `json:"shutdown_timeout,omitempty"`
 	}
 )
 
+// RPCConfig sets where the gRPC server listens; requests are given Timeout
+// to complete, 30 seconds when it is not set
 type RPCConfig struct {
-	Host string `json:"host,omitempty"`
-	Port string `json:"port,omitempty"`
+	Host    string        `json:"host,omitempty"`
+	Port    string        `json:"port,omitempty"`
+	Timeout time.Duration `json:"timeout,omitempty"`
 }
 
 func (c RPCConfig) Address() string {
@@ -49,6 +110,27 @@ func (c WebConfig) Address() string {
 	return fmt.Sprintf("%s:%s", c.Host, c.Port)
 }
 
+// AdminConfig sets where the admin API listens, apart from the public web
+// server; it listens on 127.0.0.1:8081 unless told otherwise. When Token is
+// set every request must carry it as a bearer token
+type AdminConfig struct {
+	Host  string `json:"host,omitempty"`
+	Port  string `json:"port,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+func (c AdminConfig) Address() string {
+	host, port := c.Host, c.Port
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if port == "" {
+		port = "8081"
+	}
+
+	return fmt.Sprintf("%s:%s", host, port)
+}
+
 func InitConfig(configFile string, cfg *AppConfig) error {
 	data, err := os.ReadFile(configFile)
 	if err != nil {
@@ -0,0 +1,90 @@
+package correlation
+
+import (
+	"context"
+
+	"github.com/v8tix/eda/ddd"
+	"github.com/v8tix/eda/es"
+)
+
+// Events carry the ids in their metadata under these keys
+const (
+	CorrelationIDKey = "correlation_id"
+	CausationIDKey   = "causation_id"
+)
+
+type (
+	// IDs tie events and messages back to the request that caused them; the
+	// correlation id is shared by everything a request leads to and the
+	// causation id names the request or event that directly caused it
+	IDs struct {
+		CorrelationID string
+		CausationID   string
+	}
+
+	contextKey struct{}
+
+	aggregateStore struct {
+		es.AggregateStore
+	}
+)
+
+func WithIDs(ctx context.Context, ids IDs) context.Context {
+	return context.WithValue(ctx, contextKey{}, ids)
+}
+
+func FromContext(ctx context.Context) (IDs, bool) {
+	ids, ok := ctx.Value(contextKey{}).(IDs)
+	return ids, ok
+}
+
+// Metadata returns the ids as event metadata, leaving out those that are
+// not set
+func (ids IDs) Metadata() ddd.Metadata {
+	metadata := ddd.Metadata{}
+	if ids.CorrelationID != "" {
+		metadata.Set(CorrelationIDKey, ids.CorrelationID)
+	}
+	if ids.CausationID != "" {
+		metadata.Set(CausationIDKey, ids.CausationID)
+	}
+
+	return metadata
+}
+
+// CausedBy returns the ids of an event caused by the given event; it shares
+// the correlation id of the event, or uses the event id when it has none
+func CausedBy(event ddd.Event) IDs {
+	correlationID, _ := event.Metadata().Get(CorrelationIDKey).(string)
+	if correlationID == "" {
+		correlationID = event.ID()
+	}
+
+	return IDs{
+		CorrelationID: correlationID,
+		CausationID:   event.ID(),
+	}
+}
+
+// NewAggregateStoreMiddleware stamps the ids found in the context into the
+// metadata of the events of every aggregate that is saved; it must come
+// before the middleware that publishes the events
+func NewAggregateStoreMiddleware() es.AggregateStoreMiddleware {
+	return func(store es.AggregateStore) es.AggregateStore {
+		return aggregateStore{AggregateStore: store}
+	}
+}
+
+func (s aggregateStore) Save(ctx context.Context, aggregate es.EventSourcedAggregate) error {
+	if ids, ok := FromContext(ctx); ok {
+		for _, event := range aggregate.Events() {
+			for key, value := range ids.Metadata() {
+				if event.Metadata().Get(key) == nil {
+					event.Metadata().Set(key, value)
+				}
+			}
+		}
+	}
+
+	return s.AggregateStore.Save(ctx, aggregate)
+}
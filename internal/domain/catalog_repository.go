@@ -11,6 +11,7 @@ type CatalogProduct struct {
 	Description string
 	SKU         string
 	Price       float64
+	Version     int
 }
 
 type CatalogRepository interface {
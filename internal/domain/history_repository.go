@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/v8tix/eda/ddd"
+	"github.com/v8tix/eda/es"
+)
+
+type HistoryEvent struct {
+	ID         string
+	Name       string
+	Version    int
+	OccurredAt time.Time
+	Payload    any
+	Metadata   ddd.Metadata
+}
+
+type HistoryRepository interface {
+	History(ctx context.Context, aggregateName, aggregateID string, afterVersion, limit int) ([]*HistoryEvent, error)
+	// Version returns the current version of the aggregate without loading
+	// it; an aggregate without events is at version zero
+	Version(ctx context.Context, aggregateName, aggregateID string) (int, error)
+	// LoadAsOf loads the aggregate as it was at asOf or at version; a zero
+	// asOf or version does not limit the load
+	LoadAsOf(ctx context.Context, aggregate es.EventSourcedAggregate, asOf time.Time, version int) error
+}
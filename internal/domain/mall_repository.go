@@ -9,6 +9,7 @@ type MallStore struct {
 	Name          string
 	Location      string
 	Participating bool
+	Version       int
 }
 
 type MallRepository interface {
@@ -14,6 +14,7 @@ var (
 	ErrProductPriceIsNegative = errors.Wrap(errors.ErrBadRequest, "the product price cannot be negative")
 	ErrNotAPriceIncrease      = errors.Wrap(errors.ErrBadRequest, "the price change would be a decrease")
 	ErrNotAPriceDecrease      = errors.Wrap(errors.ErrBadRequest, "the price change would be an increase")
+	ErrProductNotFound        = errors.Wrap(errors.ErrNotFound, "the product does not exist")
 )
 
 type Product struct {
@@ -23,6 +24,7 @@ type Product struct {
 	Description string
 	SKU         string
 	Price       float64
+	Removed     bool
 }
 
 var _ interface {
@@ -76,7 +78,9 @@ func (p *Product) IncreasePrice(price float64) error {
 	}
 
 	p.AddEvent(ProductPriceIncreasedEvent, &ProductPriceChanged{
-		Delta: price - p.Price,
+		Delta:         price - p.Price,
+		Price:         price,
+		PreviousPrice: p.Price,
 	})
 
 	return nil
@@ -88,7 +92,9 @@ func (p *Product) DecreasePrice(price float64) error {
 	}
 
 	p.AddEvent(ProductPriceDecreasedEvent, &ProductPriceChanged{
-		Delta: price - p.Price,
+		Delta:         price - p.Price,
+		Price:         price,
+		PreviousPrice: p.Price,
 	})
 
 	return nil
@@ -114,10 +120,16 @@ func (p *Product) ApplyEvent(event ddd.Event) error {
 		p.Description = payload.Description
 
 	case *ProductPriceChanged:
-		p.Price = p.Price + payload.Delta
+		// fill in the prices of events recorded with only the delta
+		if !payload.HasPrices() {
+			payload.PreviousPrice = p.Price
+			payload.Price = p.Price + payload.Delta
+			payload.DeltaOnly = false
+		}
+		p.Price = payload.Price
 
 	case *ProductRemoved:
-		// noop
+		p.Removed = true
 
 	default:
 		return errors.ErrInternal.Msgf("%T received the event %s with unexpected payload %T", p, event.EventName(), payload)
@@ -127,13 +139,14 @@ func (p *Product) ApplyEvent(event ddd.Event) error {
 }
 
 func (p *Product) ApplySnapshot(snapshot es.Snapshot) error {
-	switch ss := snapshot.(type) {
-	case *ProductV1:
+	switch ss := UpgradeSnapshot(snapshot).(type) {
+	case *ProductV2:
 		p.StoreID = ss.StoreID
 		p.Name = ss.Name
 		p.Description = ss.Description
 		p.SKU = ss.SKU
 		p.Price = ss.Price
+		p.Removed = ss.Removed
 
 	default:
 		return errors.ErrInternal.Msgf("%T received the unexpected snapshot %T", p, snapshot)
@@ -143,11 +156,12 @@ func (p *Product) ApplySnapshot(snapshot es.Snapshot) error {
 }
 
 func (p Product) ToSnapshot() es.Snapshot {
-	return ProductV1{
+	return ProductV2{
 		StoreID:     p.StoreID,
 		Name:        p.Name,
 		Description: p.Description,
 		SKU:         p.SKU,
 		Price:       p.Price,
+		Removed:     p.Removed,
 	}
 }
@@ -27,8 +27,19 @@ type ProductRebranded struct {
 // Key implements registry.Registerable
 func (ProductRebranded) Key() string { return ProductRebrandedEvent }
 
+// ProductPriceChanged records the change and the prices on either side of
+// it; events recorded before the prices were only carry the Delta and are
+// marked DeltaOnly when they are upcast
 type ProductPriceChanged struct {
-	Delta float64
+	Delta         float64
+	Price         float64
+	PreviousPrice float64
+	DeltaOnly     bool
+}
+
+// HasPrices reports whether the event recorded the absolute prices
+func (e ProductPriceChanged) HasPrices() bool {
+	return !e.DeltaOnly
 }
 
 type ProductRemoved struct{}
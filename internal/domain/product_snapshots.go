@@ -1,5 +1,22 @@
 package domain
 
+import (
+	"github.com/v8tix/eda/es"
+)
+
+// ProductV2 is the current product snapshot
+type ProductV2 struct {
+	StoreID     string
+	Name        string
+	Description string
+	SKU         string
+	Price       float64
+	Removed     bool
+}
+
+func (ProductV2) SnapshotName() string { return "stores.ProductV2" }
+
+// ProductV1 did not record whether the product had been removed
 type ProductV1 struct {
 	StoreID     string
 	Name        string
@@ -9,3 +26,17 @@ type ProductV1 struct {
 }
 
 func (ProductV1) SnapshotName() string { return "stores.ProductV1" }
+
+// Upgrade implements UpgradableSnapshot
+//
+// A V1 snapshot taken after the product was removed upgrades to a product
+// that is not removed; regenerate the product snapshots to correct them.
+func (ss ProductV1) Upgrade() es.Snapshot {
+	return &ProductV2{
+		StoreID:     ss.StoreID,
+		Name:        ss.Name,
+		Description: ss.Description,
+		SKU:         ss.SKU,
+		Price:       ss.Price,
+	}
+}
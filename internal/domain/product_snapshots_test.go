@@ -0,0 +1,40 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/v8tix/eda/es"
+	"github.com/v8tix/mallbots-stores/internal/domain"
+)
+
+func TestProductApplySnapshot(t *testing.T) {
+	tests := map[string]struct {
+		snapshot es.Snapshot
+		want     domain.ProductV2
+	}{
+		"V1 is upgraded": {
+			snapshot: &domain.ProductV1{StoreID: "store-1", Name: "Widget", Description: "A widget", SKU: "W-1", Price: 9.99},
+			want:     domain.ProductV2{StoreID: "store-1", Name: "Widget", Description: "A widget", SKU: "W-1", Price: 9.99},
+		},
+		"V2": {
+			snapshot: &domain.ProductV2{StoreID: "store-1", Name: "Widget", Description: "A widget", SKU: "W-1", Price: 9.99, Removed: true},
+			want:     domain.ProductV2{StoreID: "store-1", Name: "Widget", Description: "A widget", SKU: "W-1", Price: 9.99, Removed: true},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			product := domain.NewProduct("product-1")
+			if err := es.LoadSnapshot(product, tc.snapshot, 3); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := product.ToSnapshot(); got != tc.want {
+				t.Errorf("snapshot = %+v, want %+v", got, tc.want)
+			}
+			if product.Version() != 3 {
+				t.Errorf("version = %d, want 3", product.Version())
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"github.com/v8tix/eda/es"
+)
+
+// UpgradableSnapshot is implemented by snapshot versions that have been
+// superseded; Upgrade returns the snapshot in the version that follows it
+type UpgradableSnapshot interface {
+	es.Snapshot
+	Upgrade() es.Snapshot
+}
+
+// UpgradeSnapshot upgrades the snapshot one version at a time until it
+// reaches the current version
+func UpgradeSnapshot(snapshot es.Snapshot) es.Snapshot {
+	for {
+		upgradable, ok := snapshot.(UpgradableSnapshot)
+		if !ok {
+			return snapshot
+		}
+		snapshot = upgradable.Upgrade()
+	}
+}
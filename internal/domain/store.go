@@ -14,6 +14,7 @@ var (
 	ErrStoreLocationIsBlank           = errors.Wrap(errors.ErrBadRequest, "the store location cannot be blank")
 	ErrStoreIsAlreadyParticipating    = errors.Wrap(errors.ErrBadRequest, "the store is already participating")
 	ErrStoreIsAlreadyNotParticipating = errors.Wrap(errors.ErrBadRequest, "the store is already not participating")
+	ErrStoreNotFound                  = errors.Wrap(errors.ErrNotFound, "the store does not exist")
 )
 
 type Store struct {
@@ -110,7 +111,7 @@ func (s *Store) ApplyEvent(event ddd.Event) error {
 
 // ApplySnapshot implements es.Snapshotter
 func (s *Store) ApplySnapshot(snapshot es.Snapshot) error {
-	switch ss := snapshot.(type) {
+	switch ss := UpgradeSnapshot(snapshot).(type) {
 	case *StoreV1:
 		s.Name = ss.Name
 		s.Location = ss.Location
@@ -1,5 +1,10 @@
 package domain
 
+// StoreV1 is the current store snapshot.
+//
+// When the store changes shape, add StoreV2, give StoreV1 an Upgrade method
+// that returns a *StoreV2 and keep StoreV1 registered; existing snapshots
+// then continue to load until they are regenerated.
 type StoreV1 struct {
 	Name          string
 	Location      string
@@ -0,0 +1,66 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/stackus/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// translateError converts errors returned by the application, or by the
+// transaction handling around it, into gRPC status errors; eventsTable is the
+// schema qualified table the events are saved to.
+//
+// Coded errors such as domain.ErrStoreNotFound or domain.ErrNotAPriceIncrease
+// keep their codes; database conflicts and context errors are given codes of
+// their own, and anything left uncoded is reported as an internal error
+// without exposing its details to the client. The status always carries an
+// errors.ErrorType detail so that grpc-gateway and clients using
+// errors.ReceiveGRPCError are able to recover the HTTP code and type.
+func translateError(err error, eventsTable string) error {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return errors.SendGRPCError(errors.ErrCanceled.Wrap(err, "the request was canceled"))
+	case errors.Is(err, context.DeadlineExceeded):
+		return errors.SendGRPCError(errors.ErrDeadlineExceeded.Wrap(err, "the request deadline was exceeded"))
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgerrcode.UniqueViolation:
+			if isTable(pgErr, eventsTable) {
+				// another writer appended the same stream_version first; this is
+				// the same lost update that an expected version guards against
+				return errors.SendGRPCError(errors.ErrAborted.Wrap(err, "the aggregate was changed by a concurrent request; try again"))
+			}
+			return errors.SendGRPCError(errors.ErrConflict.Wrap(err, "the request conflicts with a concurrent change"))
+		case pgerrcode.SerializationFailure, pgerrcode.DeadlockDetected:
+			return errors.SendGRPCError(errors.ErrAborted.Wrap(err, "the request was aborted by a concurrent change; try again"))
+		}
+	}
+
+	switch errors.GRPCCode(err) {
+	case codes.Unknown, codes.Internal:
+		return errors.SendGRPCError(errors.ErrInternal.Wrap(err, "internal server error"))
+	}
+
+	return errors.SendGRPCError(err)
+}
+
+// isTable reports whether the error was raised by the table, which may be
+// qualified with its schema
+func isTable(pgErr *pgconn.PgError, tableName string) bool {
+	if schema, table, qualified := strings.Cut(tableName, "."); qualified {
+		return pgErr.SchemaName == schema && pgErr.TableName == table
+	}
+
+	return pgErr.TableName == tableName
+}
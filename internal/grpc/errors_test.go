@@ -0,0 +1,54 @@
+package grpc
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/stackus/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/v8tix/mallbots-stores/internal/application/commands"
+)
+
+func TestTranslateError(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want codes.Code
+	}{
+		"expected version conflict": {
+			err:  commands.ErrVersionConflict,
+			want: codes.Aborted,
+		},
+		"stream version taken by a concurrent save": {
+			err:  errors.Wrap(&pgconn.PgError{Code: pgerrcode.UniqueViolation, SchemaName: "stores", TableName: "events"}, "inserting events"),
+			want: codes.Aborted,
+		},
+		"unique violation elsewhere": {
+			err:  &pgconn.PgError{Code: pgerrcode.UniqueViolation, SchemaName: "stores", TableName: "stores"},
+			want: codes.AlreadyExists,
+		},
+		"events table of another schema": {
+			err:  &pgconn.PgError{Code: pgerrcode.UniqueViolation, SchemaName: "other", TableName: "events"},
+			want: codes.AlreadyExists,
+		},
+		"serialization failure": {
+			err:  &pgconn.PgError{Code: pgerrcode.SerializationFailure},
+			want: codes.Aborted,
+		},
+		"uncoded error": {
+			err:  fmt.Errorf("connection reset"),
+			want: codes.Internal,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := status.Code(translateError(tc.err, "stores.events")); got != tc.want {
+				t.Errorf("code = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"path"
+
+	"github.com/stackus/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/v8tix/eda/di"
+	"github.com/v8tix/mallbots-stores-proto/pb"
+)
+
+const (
+	idempotencyKey        = "idempotency-key"
+	idempotentReplayedKey = "x-idempotent-replayed"
+)
+
+var ErrIdempotencyKeyReused = errors.Wrap(errors.ErrFailedPrecondition, "the idempotency key was already used with a different request")
+
+type IdempotencyStore interface {
+	Find(ctx context.Context, key, method string) (requestHash, response []byte, aggregateVersion int, err error)
+	Save(ctx context.Context, key, method string, requestHash, response []byte, aggregateVersion int) error
+}
+
+// idempotentMethods are the methods whose responses are recorded, with the
+// response each of them returns
+var idempotentMethods = map[string]func() proto.Message{
+	pb.StoresService_CreateStore_FullMethodName: func() proto.Message { return &pb.CreateStoreResponse{} },
+	pb.StoresService_AddProduct_FullMethodName:  func() proto.Message { return &pb.AddProductResponse{} },
+}
+
+// idempotent runs the idempotent methods once per idempotency key; replays of
+// the key return the recorded response and aggregate version, provided they
+// repeat the original request, and are refused when they do not. The response
+// is recorded in the scoped transaction so it is only kept when the command
+// itself commits.
+func idempotent() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		newResponse, exists := idempotentMethods[info.FullMethod]
+		key := incomingValue(ctx, idempotencyKey)
+		if !exists || key == "" {
+			return handler(ctx, req)
+		}
+
+		// recorded under the short method name, e.g. "CreateStore"
+		method := path.Base(info.FullMethod)
+		store := di.Get(ctx, "idempotency").(IdempotencyStore)
+
+		requestHash, err := hashRequest(req)
+		if err != nil {
+			return nil, err
+		}
+
+		recordedHash, data, version, err := store.Find(ctx, key, method)
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			if !bytes.Equal(recordedHash, requestHash) {
+				return nil, ErrIdempotencyKeyReused
+			}
+			resp := newResponse()
+			if err = proto.Unmarshal(data, resp); err != nil {
+				return nil, err
+			}
+			if err = grpc.SetHeader(ctx, metadata.Pairs(idempotentReplayedKey, "true")); err != nil {
+				return nil, err
+			}
+			if version != 0 {
+				if err = setAggregateVersion(ctx, version); err != nil {
+					return nil, err
+				}
+			}
+			return resp, nil
+		}
+
+		resp, err := handler(recordAggregateVersion(ctx, &version), req)
+		if err != nil {
+			return nil, err
+		}
+
+		if data, err = proto.Marshal(resp.(proto.Message)); err != nil {
+			return nil, err
+		}
+
+		if err = store.Save(ctx, key, method, requestHash, data, version); err != nil {
+			return nil, err
+		}
+
+		return resp, nil
+	}
+}
+
+// hashRequest returns a SHA-256 hash of the deterministically serialized
+// request
+func hashRequest(req any) ([]byte, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.(proto.Message))
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(data)
+
+	return hash[:], nil
+}
@@ -0,0 +1,156 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stackus/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/v8tix/eda/di"
+	"github.com/v8tix/mallbots-stores-proto/pb"
+
+	"github.com/v8tix/mallbots-stores/internal/postgres"
+)
+
+type recordedResponse struct {
+	requestHash      []byte
+	response         []byte
+	aggregateVersion int
+}
+
+// fakeIdempotencyStore keeps the recorded responses in memory; saveErr is
+// returned by Save in place of recording the response
+type fakeIdempotencyStore struct {
+	responses map[string]recordedResponse
+	saveErr   error
+}
+
+func (s *fakeIdempotencyStore) Find(_ context.Context, key, method string) ([]byte, []byte, int, error) {
+	recorded := s.responses[key+"/"+method]
+	return recorded.requestHash, recorded.response, recorded.aggregateVersion, nil
+}
+
+func (s *fakeIdempotencyStore) Save(_ context.Context, key, method string, requestHash, response []byte, aggregateVersion int) error {
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+	s.responses[key+"/"+method] = recordedResponse{requestHash, response, aggregateVersion}
+	return nil
+}
+
+// headerStream collects the response headers set by the interceptor
+type headerStream struct {
+	header metadata.MD
+}
+
+func (s *headerStream) Method() string { return pb.StoresService_CreateStore_FullMethodName }
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+func (s *headerStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+func (s *headerStream) SetTrailer(metadata.MD) error    { return nil }
+
+func TestIdempotent(t *testing.T) {
+	request := &pb.CreateStoreRequest{Name: "Store", Location: "Mall"}
+	requestHash, err := hashRequest(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := proto.Marshal(&pb.CreateStoreResponse{Id: "store-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		recorded    map[string]recordedResponse
+		saveErr     error
+		wantHandled bool
+		wantID      string
+		wantHeader  map[string]string
+		wantErr     error
+	}{
+		"first request": {
+			wantHandled: true,
+			wantID:      "store-2",
+			wantHeader:  map[string]string{aggregateVersionKey: "1"},
+		},
+		"replay": {
+			recorded: map[string]recordedResponse{
+				"key-1/CreateStore": {requestHash, recorded, 1},
+			},
+			wantID:     "store-1",
+			wantHeader: map[string]string{idempotentReplayedKey: "true", aggregateVersionKey: "1"},
+		},
+		"key reused with a different request": {
+			recorded: map[string]recordedResponse{
+				"key-1/CreateStore": {[]byte("another request"), recorded, 1},
+			},
+			wantErr: ErrIdempotencyKeyReused,
+		},
+		"key in use by a concurrent request": {
+			saveErr:     postgres.ErrIdempotencyKeyInUse,
+			wantHandled: true,
+			wantErr:     postgres.ErrIdempotencyKeyInUse,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := &fakeIdempotencyStore{responses: make(map[string]recordedResponse), saveErr: tc.saveErr}
+			for key, response := range tc.recorded {
+				store.responses[key] = response
+			}
+
+			container := di.New()
+			container.AddScoped("idempotency", func(di.Container) (any, error) {
+				return store, nil
+			})
+
+			stream := &headerStream{}
+			ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(idempotencyKey, "key-1"))
+			ctx = container.Scoped(ctx)
+
+			var handled bool
+			handler := func(ctx context.Context, _ any) (any, error) {
+				handled = true
+				if err := setAggregateVersion(ctx, 1); err != nil {
+					return nil, err
+				}
+				return &pb.CreateStoreResponse{Id: "store-2"}, nil
+			}
+
+			resp, err := idempotent()(ctx, request, &grpc.UnaryServerInfo{FullMethod: pb.StoresService_CreateStore_FullMethodName}, handler)
+			if handled != tc.wantHandled {
+				t.Errorf("handled = %t, want %t", handled, tc.wantHandled)
+			}
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if id := resp.(*pb.CreateStoreResponse).GetId(); id != tc.wantID {
+				t.Errorf("id = %q, want %q", id, tc.wantID)
+			}
+			for key, want := range tc.wantHeader {
+				if got := stream.header.Get(key); len(got) != 1 || got[0] != want {
+					t.Errorf("header %s = %v, want %q", key, got, want)
+				}
+			}
+			if tc.wantHandled {
+				if saved := store.responses["key-1/CreateStore"]; saved.aggregateVersion != 1 {
+					t.Errorf("saved aggregate version = %d, want 1", saved.aggregateVersion)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package grpc
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/v8tix/eda/di"
+)
+
+// correlated places the correlation ids of the request on the context
+func correlated() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(correlate(ctx), req)
+	}
+}
+
+// transactional runs every request with its own scoped container and
+// commits the scoped transaction when the request succeeds. Errors are
+// translated into gRPC status errors; those reported as internal errors are
+// logged first, as their details are not passed on to the client.
+func transactional(container di.Container, eventsTable string, logger zerolog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		ctx = container.Scoped(ctx)
+		defer func(tx *sql.Tx) {
+			if p := recover(); p != nil {
+				_ = tx.Rollback()
+				panic(p)
+			}
+			if err = closeTx(tx, err); err != nil {
+				translated := translateError(err, eventsTable)
+				if status.Code(translated) == codes.Internal {
+					logger.Error().Err(err).Msgf("internal error in %s", info.FullMethod)
+				}
+				err = translated
+			}
+		}(di.Get(ctx, "tx").(*sql.Tx))
+
+		return handler(ctx, req)
+	}
+}
+
+func closeTx(tx *sql.Tx, err error) error {
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,112 @@
+package grpc
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stackus/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/v8tix/mallbots-stores/internal/correlation"
+)
+
+// The stores protos are shared with other services and do not carry
+// versions, points in time or idempotency keys; callers exchange them using
+// request and response metadata.
+// grpc-gateway clients use the "Grpc-Metadata-" prefixed HTTP headers.
+const (
+	expectedVersionKey  = "x-expected-version"
+	aggregateVersionKey = "x-aggregate-version"
+	asOfKey             = "x-as-of"
+	asOfVersionKey      = "x-as-of-version"
+	correlationIDKey    = "x-correlation-id"
+	requestIDKey        = "x-request-id"
+)
+
+func incomingValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(key); len(values) != 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func expectedVersion(ctx context.Context) (int, error) {
+	value := incomingValue(ctx, expectedVersionKey)
+	if value == "" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, errors.ErrInvalidArgument.Msgf("the %s metadata must be a positive integer", expectedVersionKey)
+	}
+
+	return version, nil
+}
+
+// asOf reads the point in time, as an RFC 3339 timestamp, and the version to
+// read an aggregate at
+func asOf(ctx context.Context) (at time.Time, version int, err error) {
+	if value := incomingValue(ctx, asOfKey); value != "" {
+		at, err = time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return at, 0, errors.ErrInvalidArgument.Msgf("the %s metadata must be an RFC 3339 timestamp", asOfKey)
+		}
+	}
+
+	if value := incomingValue(ctx, asOfVersionKey); value != "" {
+		version, err = strconv.Atoi(value)
+		if err != nil || version < 1 {
+			return at, 0, errors.ErrInvalidArgument.Msgf("the %s metadata must be a positive integer", asOfVersionKey)
+		}
+	}
+
+	return at, version, nil
+}
+
+// correlate places the correlation ids of the request on the context; the
+// request id, generated when the caller sends none, is the cause of the
+// events the request leads to and also their correlation id unless the
+// caller sends one. Both are returned in the response metadata.
+func correlate(ctx context.Context) context.Context {
+	requestID := incomingValue(ctx, requestIDKey)
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+	correlationID := incomingValue(ctx, correlationIDKey)
+	if correlationID == "" {
+		correlationID = requestID
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, requestID, correlationIDKey, correlationID))
+
+	return correlation.WithIDs(ctx, correlation.IDs{
+		CorrelationID: correlationID,
+		CausationID:   requestID,
+	})
+}
+
+type aggregateVersionRecorderKey struct{}
+
+// recordAggregateVersion has setAggregateVersion also record the version it
+// sets into version
+func recordAggregateVersion(ctx context.Context, version *int) context.Context {
+	return context.WithValue(ctx, aggregateVersionRecorderKey{}, version)
+}
+
+func setAggregateVersion(ctx context.Context, version int) error {
+	if recorded, ok := ctx.Value(aggregateVersionRecorderKey{}).(*int); ok {
+		*recorded = version
+	}
+
+	return grpc.SetHeader(ctx, metadata.Pairs(aggregateVersionKey, strconv.Itoa(version)))
+}
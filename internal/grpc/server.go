@@ -4,32 +4,46 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
 
+	"github.com/v8tix/eda/di"
 	"github.com/v8tix/mallbots-stores-proto/pb"
 
 	"github.com/v8tix/mallbots-stores/internal/application"
 	"github.com/v8tix/mallbots-stores/internal/application/commands"
 	"github.com/v8tix/mallbots-stores/internal/application/queries"
 	"github.com/v8tix/mallbots-stores/internal/domain"
+	"github.com/v8tix/mallbots-stores/internal/rpc"
 )
 
+// server maps the stores API onto the application; the interceptors added
+// by RegisterServer give every request its scoped container and transaction
 type server struct {
-	app application.App
 	pb.UnimplementedStoresServiceServer
 }
 
 var _ pb.StoresServiceServer = (*server)(nil)
 
-func RegisterServer(_ context.Context, app application.App, registrar grpc.ServiceRegistrar) error {
-	pb.RegisterStoresServiceServer(registrar, server{app: app})
+func RegisterServer(container di.Container, registrar grpc.ServiceRegistrar, interceptors *rpc.Interceptors, eventsTable string, logger zerolog.Logger) error {
+	interceptors.Add(pb.StoresService_ServiceDesc.ServiceName,
+		correlated(),
+		transactional(container, eventsTable, logger),
+		idempotent(),
+	)
+	pb.RegisterStoresServiceServer(registrar, server{})
 	return nil
 }
 
+// app returns the application of the request's scoped container
+func (s server) app(ctx context.Context) application.App {
+	return di.Get(ctx, "app").(application.App)
+}
+
 func (s server) CreateStore(ctx context.Context, request *pb.CreateStoreRequest) (*pb.CreateStoreResponse, error) {
 	storeID := uuid.New().String()
 
-	err := s.app.CreateStore(ctx, commands.CreateStore{
+	version, err := s.app(ctx).CreateStore(ctx, commands.CreateStore{
 		ID:       storeID,
 		Name:     request.GetName(),
 		Location: request.GetLocation(),
@@ -38,53 +52,103 @@ func (s server) CreateStore(ctx context.Context, request *pb.CreateStoreRequest)
 		return nil, err
 	}
 
+	if err = setAggregateVersion(ctx, version); err != nil {
+		return nil, err
+	}
+
 	return &pb.CreateStoreResponse{
 		Id: storeID,
 	}, nil
 }
 
 func (s server) EnableParticipation(ctx context.Context, request *pb.EnableParticipationRequest) (*pb.EnableParticipationResponse, error) {
-	err := s.app.EnableParticipation(ctx, commands.EnableParticipation{
-		ID: request.GetId(),
+	expected, err := expectedVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := s.app(ctx).EnableParticipation(ctx, commands.EnableParticipation{
+		ID:              request.GetId(),
+		ExpectedVersion: expected,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	if err = setAggregateVersion(ctx, version); err != nil {
+		return nil, err
+	}
+
 	return &pb.EnableParticipationResponse{}, nil
 }
 
 func (s server) DisableParticipation(ctx context.Context, request *pb.DisableParticipationRequest) (*pb.DisableParticipationResponse, error) {
-	err := s.app.DisableParticipation(ctx, commands.DisableParticipation{
-		ID: request.GetId(),
+	expected, err := expectedVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := s.app(ctx).DisableParticipation(ctx, commands.DisableParticipation{
+		ID:              request.GetId(),
+		ExpectedVersion: expected,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	if err = setAggregateVersion(ctx, version); err != nil {
+		return nil, err
+	}
+
 	return &pb.DisableParticipationResponse{}, nil
 }
 
 func (s server) RebrandStore(ctx context.Context, request *pb.RebrandStoreRequest) (*pb.RebrandStoreResponse, error) {
-	err := s.app.RebrandStore(ctx, commands.RebrandStore{
-		ID:   request.GetId(),
-		Name: request.GetName(),
+	expected, err := expectedVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := s.app(ctx).RebrandStore(ctx, commands.RebrandStore{
+		ID:              request.GetId(),
+		Name:            request.GetName(),
+		ExpectedVersion: expected,
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = setAggregateVersion(ctx, version); err != nil {
+		return nil, err
+	}
 
-	return &pb.RebrandStoreResponse{}, err
+	return &pb.RebrandStoreResponse{}, nil
 }
 
 func (s server) GetStore(ctx context.Context, request *pb.GetStoreRequest) (*pb.GetStoreResponse, error) {
-	store, err := s.app.GetStore(ctx, queries.GetStore{ID: request.GetId()})
+	at, version, err := asOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := s.app(ctx).GetStore(ctx, queries.GetStore{
+		ID:          request.GetId(),
+		AsOf:        at,
+		AsOfVersion: version,
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	if err = setAggregateVersion(ctx, store.Version); err != nil {
+		return nil, err
+	}
+
 	return &pb.GetStoreResponse{Store: s.storeFromDomain(store)}, nil
 }
 
 func (s server) GetStores(ctx context.Context, request *pb.GetStoresRequest) (*pb.GetStoresResponse, error) {
-	stores, err := s.app.GetStores(ctx, queries.GetStores{})
+	stores, err := s.app(ctx).GetStores(ctx, queries.GetStores{})
 	if err != nil {
 		return nil, err
 	}
@@ -100,7 +164,7 @@ func (s server) GetStores(ctx context.Context, request *pb.GetStoresRequest) (*p
 }
 
 func (s server) GetParticipatingStores(ctx context.Context, request *pb.GetParticipatingStoresRequest) (*pb.GetParticipatingStoresResponse, error) {
-	stores, err := s.app.GetParticipatingStores(ctx, queries.GetParticipatingStores{})
+	stores, err := s.app(ctx).GetParticipatingStores(ctx, queries.GetParticipatingStores{})
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +181,7 @@ func (s server) GetParticipatingStores(ctx context.Context, request *pb.GetParti
 
 func (s server) AddProduct(ctx context.Context, request *pb.AddProductRequest) (*pb.AddProductResponse, error) {
 	id := uuid.New().String()
-	err := s.app.AddProduct(ctx, commands.AddProduct{
+	version, err := s.app(ctx).AddProduct(ctx, commands.AddProduct{
 		ID:          id,
 		StoreID:     request.GetStoreId(),
 		Name:        request.GetName(),
@@ -129,55 +193,125 @@ func (s server) AddProduct(ctx context.Context, request *pb.AddProductRequest) (
 		return nil, err
 	}
 
+	if err = setAggregateVersion(ctx, version); err != nil {
+		return nil, err
+	}
+
 	return &pb.AddProductResponse{Id: id}, nil
 }
 
 func (s server) RebrandProduct(ctx context.Context, request *pb.RebrandProductRequest) (*pb.RebrandProductResponse, error) {
-	err := s.app.RebrandProduct(ctx, commands.RebrandProduct{
-		ID:          request.GetId(),
-		Name:        request.GetName(),
-		Description: request.GetDescription(),
+	expected, err := expectedVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := s.app(ctx).RebrandProduct(ctx, commands.RebrandProduct{
+		ID:              request.GetId(),
+		Name:            request.GetName(),
+		Description:     request.GetDescription(),
+		ExpectedVersion: expected,
 	})
-	return &pb.RebrandProductResponse{}, err
+	if err != nil {
+		return nil, err
+	}
+
+	if err = setAggregateVersion(ctx, version); err != nil {
+		return nil, err
+	}
+
+	return &pb.RebrandProductResponse{}, nil
 }
 
 func (s server) IncreaseProductPrice(ctx context.Context, request *pb.IncreaseProductPriceRequest) (*pb.IncreaseProductPriceResponse, error) {
-	err := s.app.IncreaseProductPrice(ctx, commands.IncreaseProductPrice{
-		ID:    request.GetId(),
-		Price: request.GetPrice(),
+	expected, err := expectedVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := s.app(ctx).IncreaseProductPrice(ctx, commands.IncreaseProductPrice{
+		ID:              request.GetId(),
+		Price:           request.GetPrice(),
+		ExpectedVersion: expected,
 	})
-	return &pb.IncreaseProductPriceResponse{}, err
+	if err != nil {
+		return nil, err
+	}
+
+	if err = setAggregateVersion(ctx, version); err != nil {
+		return nil, err
+	}
+
+	return &pb.IncreaseProductPriceResponse{}, nil
 }
 
 func (s server) DecreaseProductPrice(ctx context.Context, request *pb.DecreaseProductPriceRequest) (*pb.DecreaseProductPriceResponse, error) {
-	err := s.app.DecreaseProductPrice(ctx, commands.DecreaseProductPrice{
-		ID:    request.GetId(),
-		Price: request.GetPrice(),
+	expected, err := expectedVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := s.app(ctx).DecreaseProductPrice(ctx, commands.DecreaseProductPrice{
+		ID:              request.GetId(),
+		Price:           request.GetPrice(),
+		ExpectedVersion: expected,
 	})
-	return &pb.DecreaseProductPriceResponse{}, err
+	if err != nil {
+		return nil, err
+	}
+
+	if err = setAggregateVersion(ctx, version); err != nil {
+		return nil, err
+	}
+
+	return &pb.DecreaseProductPriceResponse{}, nil
 }
 
 func (s server) RemoveProduct(ctx context.Context, request *pb.RemoveProductRequest) (*pb.RemoveProductResponse, error) {
-	err := s.app.RemoveProduct(ctx, commands.RemoveProduct{
-		ID: request.GetId(),
+	expected, err := expectedVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := s.app(ctx).RemoveProduct(ctx, commands.RemoveProduct{
+		ID:              request.GetId(),
+		ExpectedVersion: expected,
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return &pb.RemoveProductResponse{}, err
+	if err = setAggregateVersion(ctx, version); err != nil {
+		return nil, err
+	}
+
+	return &pb.RemoveProductResponse{}, nil
 }
 
 func (s server) GetProduct(ctx context.Context, request *pb.GetProductRequest) (*pb.GetProductResponse, error) {
-	product, err := s.app.GetProduct(ctx, queries.GetProduct{
-		ID: request.GetId(),
+	at, version, err := asOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	product, err := s.app(ctx).GetProduct(ctx, queries.GetProduct{
+		ID:          request.GetId(),
+		AsOf:        at,
+		AsOfVersion: version,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	if err = setAggregateVersion(ctx, product.Version); err != nil {
+		return nil, err
+	}
+
 	return &pb.GetProductResponse{Product: s.productFromDomain(product)}, nil
 }
 
 func (s server) GetCatalog(ctx context.Context, request *pb.GetCatalogRequest) (*pb.GetCatalogResponse, error) {
-	products, err := s.app.GetCatalog(ctx, queries.GetCatalog{StoreID: request.GetStoreId()})
+	products, err := s.app(ctx).GetCatalog(ctx, queries.GetCatalog{StoreID: request.GetStoreId()})
 	if err != nil {
 		return nil, err
 	}
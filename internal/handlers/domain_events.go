@@ -6,7 +6,15 @@ import (
 	"github.com/v8tix/eda/am"
 	"github.com/v8tix/eda/ddd"
 	"github.com/v8tix/mallbots-stores-proto/pb"
+	"github.com/v8tix/mallbots-stores/internal/correlation"
 	"github.com/v8tix/mallbots-stores/internal/domain"
+	"github.com/v8tix/mallbots-stores/internal/outbox"
+)
+
+// Integration event metadata keys
+const (
+	PriceMetadataKey         = "price"
+	PreviousPriceMetadataKey = "previous_price"
 )
 
 type domainHandlers[T ddd.AggregateEvent] struct {
@@ -66,7 +74,7 @@ func (h domainHandlers[T]) onStoreCreated(ctx context.Context, event ddd.Aggrega
 			Id:       event.AggregateID(),
 			Name:     payload.Name,
 			Location: payload.Location,
-		}),
+		}, aggregateMetadata(event)),
 	)
 }
 
@@ -75,7 +83,7 @@ func (h domainHandlers[T]) onStoreParticipationEnabled(ctx context.Context, even
 		ddd.NewEvent(pb.StoreParticipatingToggledEvent, &pb.StoreParticipationToggled{
 			Id:            event.AggregateID(),
 			Participating: true,
-		}),
+		}, aggregateMetadata(event)),
 	)
 }
 
@@ -84,7 +92,7 @@ func (h domainHandlers[T]) onStoreParticipationDisabled(ctx context.Context, eve
 		ddd.NewEvent(pb.StoreParticipatingToggledEvent, &pb.StoreParticipationToggled{
 			Id:            event.AggregateID(),
 			Participating: false,
-		}),
+		}, aggregateMetadata(event)),
 	)
 }
 
@@ -94,7 +102,7 @@ func (h domainHandlers[T]) onStoreRebranded(ctx context.Context, event ddd.Aggre
 		ddd.NewEvent(pb.StoreRebrandedEvent, &pb.StoreRebranded{
 			Id:   event.AggregateID(),
 			Name: payload.Name,
-		}),
+		}, aggregateMetadata(event)),
 	)
 }
 
@@ -108,7 +116,7 @@ func (h domainHandlers[T]) onProductAdded(ctx context.Context, event ddd.Aggrega
 			Description: payload.Description,
 			Sku:         payload.SKU,
 			Price:       payload.Price,
-		}),
+		}, aggregateMetadata(event)),
 	)
 }
 
@@ -119,7 +127,7 @@ func (h domainHandlers[T]) onProductRebranded(ctx context.Context, event ddd.Agg
 			Id:          event.AggregateID(),
 			Name:        payload.Name,
 			Description: payload.Description,
-		}),
+		}, aggregateMetadata(event)),
 	)
 }
 
@@ -129,7 +137,7 @@ func (h domainHandlers[T]) onProductPriceIncreased(ctx context.Context, event dd
 		ddd.NewEvent(pb.ProductPriceIncreasedEvent, &pb.ProductPriceChanged{
 			Id:    event.AggregateID(),
 			Delta: payload.Delta,
-		}),
+		}, aggregateMetadata(event), priceMetadata(payload)),
 	)
 }
 
@@ -139,14 +147,39 @@ func (h domainHandlers[T]) onProductPriceDecreased(ctx context.Context, event dd
 		ddd.NewEvent(pb.ProductPriceDecreasedEvent, &pb.ProductPriceChanged{
 			Id:    event.AggregateID(),
 			Delta: payload.Delta,
-		}),
+		}, aggregateMetadata(event), priceMetadata(payload)),
 	)
 }
 
+// aggregateMetadata identifies the aggregate and the version of it the
+// integration event was published for; consumers use the version to detect
+// missed or repeated events. The integration event is correlated with the
+// domain event that caused it.
+func aggregateMetadata(event ddd.AggregateEvent) ddd.Metadata {
+	metadata := correlation.CausedBy(event).Metadata()
+	metadata.Set(outbox.AggregateIDMetadataKey, event.AggregateID())
+	metadata.Set(outbox.AggregateNameMetadataKey, event.AggregateName())
+	metadata.Set(outbox.AggregateVersionMetadataKey, event.AggregateVersion())
+
+	return metadata
+}
+
+// priceMetadata carries the absolute prices of a price change alongside the
+// delta; pb.ProductPriceChanged has no fields for them
+func priceMetadata(payload *domain.ProductPriceChanged) ddd.Metadata {
+	metadata := ddd.Metadata{}
+	if payload.HasPrices() {
+		metadata.Set(PriceMetadataKey, payload.Price)
+		metadata.Set(PreviousPriceMetadataKey, payload.PreviousPrice)
+	}
+
+	return metadata
+}
+
 func (h domainHandlers[T]) onProductRemoved(ctx context.Context, event ddd.AggregateEvent) error {
 	return h.publisher.Publish(ctx, pb.ProductAggregateChannel,
 		ddd.NewEvent(pb.ProductRemovedEvent, &pb.ProductRemoved{
 			Id: event.AggregateID(),
-		}),
+		}, aggregateMetadata(event)),
 	)
 }
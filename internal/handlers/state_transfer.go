@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/stackus/errors"
+
+	"github.com/v8tix/eda/am"
+	"github.com/v8tix/eda/ddd"
+	"github.com/v8tix/eda/di"
+	"github.com/v8tix/eda/es"
+	"github.com/v8tix/mallbots-stores-proto/pb"
+	"github.com/v8tix/mallbots-stores/internal/domain"
+	"github.com/v8tix/mallbots-stores/internal/statepb"
+)
+
+// Full state integration events; the statepb messages carry the version of
+// the aggregate alongside its state. A removed product is published as a
+// tombstone carrying only its id and store id.
+const (
+	StoreSnapshotEvent    = "storesapi.StoreSnapshot"
+	ProductSnapshotEvent  = "storesapi.ProductSnapshot"
+	ProductTombstoneEvent = "storesapi.ProductTombstone"
+)
+
+// savedAggregateKey hands the aggregate that raised an event to the state
+// transfer handlers; it is set after the events are stored and so is never
+// persisted with them
+const savedAggregateKey = "saved_aggregate"
+
+var ErrSavedAggregateMissing = errors.Wrap(errors.ErrInternal, "the saved aggregate was not handed to the state transfer handlers")
+
+// stateTransferHandlers publish the full state of a store or product after
+// every change so that consumers can upsert it instead of folding events
+type stateTransferHandlers[T ddd.AggregateEvent] struct {
+	publisher am.MessagePublisher[ddd.Event]
+}
+
+var _ ddd.EventHandler[ddd.AggregateEvent] = (*stateTransferHandlers[ddd.AggregateEvent])(nil)
+
+func NewStateTransferHandlers(publisher am.MessagePublisher[ddd.Event]) ddd.EventHandler[ddd.AggregateEvent] {
+	return stateTransferHandlers[ddd.AggregateEvent]{
+		publisher: publisher,
+	}
+}
+
+// NewStateTransferMiddleware hands each saved aggregate to the state
+// transfer handlers along with its events, so that the handlers publish the
+// aggregate's state without loading it again. It must be inside
+// es.NewEventPublisher so that it runs before the events are published.
+func NewStateTransferMiddleware() es.AggregateStoreMiddleware {
+	return func(store es.AggregateStore) es.AggregateStore {
+		return stateTransferStore{AggregateStore: store}
+	}
+}
+
+type stateTransferStore struct {
+	es.AggregateStore
+}
+
+func (s stateTransferStore) Save(ctx context.Context, aggregate es.EventSourcedAggregate) error {
+	if err := s.AggregateStore.Save(ctx, aggregate); err != nil {
+		return err
+	}
+
+	for _, event := range aggregate.Events() {
+		event.Metadata().Set(savedAggregateKey, aggregate)
+	}
+
+	return nil
+}
+
+func RegisterStateTransferHandlers(subscriber ddd.EventSubscriber[ddd.AggregateEvent], handlers ddd.EventHandler[ddd.AggregateEvent]) {
+	subscriber.Subscribe(handlers,
+		domain.StoreCreatedEvent,
+		domain.StoreParticipationEnabledEvent,
+		domain.StoreParticipationDisabledEvent,
+		domain.StoreRebrandedEvent,
+		domain.ProductAddedEvent,
+		domain.ProductRebrandedEvent,
+		domain.ProductPriceIncreasedEvent,
+		domain.ProductPriceDecreasedEvent,
+		domain.ProductRemovedEvent,
+	)
+}
+
+func RegisterStateTransferHandlersTx(container di.Container) {
+	handlers := ddd.EventHandlerFunc[ddd.AggregateEvent](func(ctx context.Context, event ddd.AggregateEvent) error {
+		stateTransferHandlers := di.Get(ctx, "stateTransferHandlers").(ddd.EventHandler[ddd.AggregateEvent])
+
+		return stateTransferHandlers.HandleEvent(ctx, event)
+	})
+
+	subscriber := container.Get("domainDispatcher").(*ddd.EventDispatcher[ddd.AggregateEvent])
+
+	RegisterStateTransferHandlers(subscriber, handlers)
+}
+
+func (h stateTransferHandlers[T]) HandleEvent(ctx context.Context, event T) error {
+	switch event.AggregateName() {
+	case domain.StoreAggregate:
+		return h.onStoreChanged(ctx, event)
+	case domain.ProductAggregate:
+		return h.onProductChanged(ctx, event)
+	}
+	return nil
+}
+
+// onStoreChanged publishes the store once the last of the events saved with
+// it has been handled
+func (h stateTransferHandlers[T]) onStoreChanged(ctx context.Context, event ddd.AggregateEvent) error {
+	store, ok := event.Metadata().Get(savedAggregateKey).(*domain.Store)
+	if !ok {
+		return ErrSavedAggregateMissing
+	}
+
+	if store.PendingVersion() != event.AggregateVersion() {
+		return nil
+	}
+
+	return h.publisher.Publish(ctx, pb.StoreAggregateChannel,
+		ddd.NewEvent(StoreSnapshotEvent, &statepb.StoreSnapshot{
+			Id:            store.ID(),
+			Name:          store.Name,
+			Location:      store.Location,
+			Participating: store.Participating,
+			Version:       int64(event.AggregateVersion()),
+		}, aggregateMetadata(event)),
+	)
+}
+
+// onProductChanged publishes the product, or its tombstone once it has been
+// removed, when the last of the events saved with it has been handled
+func (h stateTransferHandlers[T]) onProductChanged(ctx context.Context, event ddd.AggregateEvent) error {
+	product, ok := event.Metadata().Get(savedAggregateKey).(*domain.Product)
+	if !ok {
+		return ErrSavedAggregateMissing
+	}
+
+	if product.PendingVersion() != event.AggregateVersion() {
+		return nil
+	}
+
+	if event.EventName() == domain.ProductRemovedEvent {
+		return h.publisher.Publish(ctx, pb.ProductAggregateChannel,
+			ddd.NewEvent(ProductTombstoneEvent, &statepb.ProductTombstone{
+				Id:      product.ID(),
+				StoreId: product.StoreID,
+				Version: int64(event.AggregateVersion()),
+			}, aggregateMetadata(event)),
+		)
+	}
+
+	return h.publisher.Publish(ctx, pb.ProductAggregateChannel,
+		ddd.NewEvent(ProductSnapshotEvent, &statepb.ProductSnapshot{
+			Id:          product.ID(),
+			StoreId:     product.StoreID,
+			Name:        product.Name,
+			Description: product.Description,
+			Sku:         product.SKU,
+			Price:       product.Price,
+			Version:     int64(event.AggregateVersion()),
+		}, aggregateMetadata(event)),
+	)
+}
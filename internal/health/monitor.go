@@ -0,0 +1,215 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	defaultInterval = 10 * time.Second
+	defaultTimeout  = 2 * time.Second
+)
+
+type (
+	// Check reports whether a dependency of the service can be used
+	Check func(ctx context.Context) error
+
+	// Monitor runs its checks periodically and reports the service as ready
+	// while every check passes. The results drive the grpc.health.v1 service
+	// and the /readyz endpoint; /healthz only reports whether the service is
+	// up. Both report the service as unavailable once it is shutting down.
+	Monitor struct {
+		services []string
+		interval time.Duration
+		timeout  time.Duration
+		server   *health.Server
+
+		mu           sync.RWMutex
+		checks       map[string]Check
+		results      map[string]error
+		checked      bool
+		shuttingDown bool
+	}
+
+	checkStatus struct {
+		Status string `json:"status"`
+		Error  string `json:"error,omitempty"`
+	}
+)
+
+// NewMonitor builds a Monitor reporting on the overall health and on each
+// named gRPC service
+func NewMonitor(services ...string) *Monitor {
+	server := health.NewServer()
+	server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	for _, service := range services {
+		server.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+
+	return &Monitor{
+		services: services,
+		interval: defaultInterval,
+		timeout:  defaultTimeout,
+		server:   server,
+		checks:   make(map[string]Check),
+		results:  make(map[string]error),
+	}
+}
+
+// AddCheck adds a check; checks must be added before the monitor runs
+func (m *Monitor) AddCheck(name string, check Check) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.checks[name] = check
+}
+
+// RegisterServer registers the grpc.health.v1 service
+func (m *Monitor) RegisterServer(registrar grpc.ServiceRegistrar) {
+	healthpb.RegisterHealthServer(registrar, m.server)
+}
+
+// Run checks immediately and then every interval until ctx is done, when
+// the service is reported as shutting down
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.runChecks(ctx)
+
+		select {
+		case <-ctx.Done():
+			m.shutdown()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Live handles /healthz
+func (m *Monitor) Live(w http.ResponseWriter, _ *http.Request) {
+	m.mu.RLock()
+	shuttingDown := m.shuttingDown
+	m.mu.RUnlock()
+
+	if shuttingDown {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "shutting down"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+}
+
+// Ready handles /readyz; the response lists the result of every check
+func (m *Monitor) Ready(w http.ResponseWriter, _ *http.Request) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	checks := make(map[string]checkStatus, len(m.checks))
+	for name := range m.checks {
+		err, checked := m.results[name]
+		switch {
+		case !checked:
+			checks[name] = checkStatus{Status: "unknown"}
+		case err != nil:
+			checks[name] = checkStatus{Status: "failing", Error: err.Error()}
+		default:
+			checks[name] = checkStatus{Status: "ok"}
+		}
+	}
+
+	status, code := "ready", http.StatusOK
+	switch {
+	case m.shuttingDown:
+		status, code = "shutting down", http.StatusServiceUnavailable
+	case !m.ready():
+		status, code = "not ready", http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, code, map[string]any{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+func (m *Monitor) runChecks(ctx context.Context) {
+	m.mu.RLock()
+	checks := make(map[string]Check, len(m.checks))
+	names := make([]string, 0, len(m.checks))
+	for name, check := range m.checks {
+		checks[name] = check
+		names = append(names, name)
+	}
+	m.mu.RUnlock()
+	sort.Strings(names)
+
+	results := make(map[string]error, len(names))
+	for _, name := range names {
+		checkCtx, cancel := context.WithTimeout(ctx, m.timeout)
+		results[name] = checks[name](checkCtx)
+		cancel()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.shuttingDown || ctx.Err() != nil {
+		return
+	}
+	m.results = results
+	m.checked = true
+	m.setServingStatus(m.ready())
+}
+
+// ready reports whether every check passed the last time it ran; the
+// caller holds the lock
+func (m *Monitor) ready() bool {
+	if !m.checked || m.shuttingDown {
+		return false
+	}
+	for _, err := range m.results {
+		if err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m *Monitor) shutdown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.shuttingDown = true
+	// every service is NOT_SERVING from now on, whatever it is set to
+	m.server.Shutdown()
+}
+
+// setServingStatus sets the status of the overall health and every named
+// service; the caller holds the lock
+func (m *Monitor) setServingStatus(serving bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+
+	m.server.SetServingStatus("", status)
+	for _, service := range m.services {
+		m.server.SetServingStatus(service, status)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -25,55 +25,55 @@ func LogApplicationAccess(application application.App, logger zerolog.Logger) Ap
 	}
 }
 
-func (a Application) CreateStore(ctx context.Context, cmd commands.CreateStore) (err error) {
+func (a Application) CreateStore(ctx context.Context, cmd commands.CreateStore) (version int, err error) {
 	a.logger.Info().Msg("--> Stores.CreateStore")
 	defer func() { a.logger.Info().Err(err).Msg("<-- Stores.CreateStore") }()
 	return a.App.CreateStore(ctx, cmd)
 }
 
-func (a Application) EnableParticipation(ctx context.Context, cmd commands.EnableParticipation) (err error) {
+func (a Application) EnableParticipation(ctx context.Context, cmd commands.EnableParticipation) (version int, err error) {
 	a.logger.Info().Msg("--> Stores.EnableParticipation")
 	defer func() { a.logger.Info().Err(err).Msg("<-- Stores.EnableParticipation") }()
 	return a.App.EnableParticipation(ctx, cmd)
 }
 
-func (a Application) DisableParticipation(ctx context.Context, cmd commands.DisableParticipation) (err error) {
+func (a Application) DisableParticipation(ctx context.Context, cmd commands.DisableParticipation) (version int, err error) {
 	a.logger.Info().Msg("--> Stores.DisableParticipation")
 	defer func() { a.logger.Info().Err(err).Msg("<-- Stores.DisableParticipation") }()
 	return a.App.DisableParticipation(ctx, cmd)
 }
 
-func (a Application) RebrandStore(ctx context.Context, cmd commands.RebrandStore) (err error) {
+func (a Application) RebrandStore(ctx context.Context, cmd commands.RebrandStore) (version int, err error) {
 	a.logger.Info().Msg("--> Stores.RebrandStore")
 	defer func() { a.logger.Info().Err(err).Msg("<-- Stores.RebrandStore") }()
 	return a.App.RebrandStore(ctx, cmd)
 }
 
-func (a Application) AddProduct(ctx context.Context, cmd commands.AddProduct) (err error) {
+func (a Application) AddProduct(ctx context.Context, cmd commands.AddProduct) (version int, err error) {
 	a.logger.Info().Msg("--> Stores.AddProduct")
 	defer func() { a.logger.Info().Err(err).Msg("<-- Stores.AddProduct") }()
 	return a.App.AddProduct(ctx, cmd)
 }
 
-func (a Application) RebrandProduct(ctx context.Context, cmd commands.RebrandProduct) (err error) {
+func (a Application) RebrandProduct(ctx context.Context, cmd commands.RebrandProduct) (version int, err error) {
 	a.logger.Info().Msg("--> Products.RebrandProduct")
 	defer func() { a.logger.Info().Err(err).Msg("<-- Products.RebrandProduct") }()
 	return a.App.RebrandProduct(ctx, cmd)
 }
 
-func (a Application) IncreaseProductPrice(ctx context.Context, cmd commands.IncreaseProductPrice) (err error) {
+func (a Application) IncreaseProductPrice(ctx context.Context, cmd commands.IncreaseProductPrice) (version int, err error) {
 	a.logger.Info().Msg("--> Products.IncreaseProductPrice")
 	defer func() { a.logger.Info().Err(err).Msg("<-- Products.IncreaseProductPrice") }()
 	return a.App.IncreaseProductPrice(ctx, cmd)
 }
 
-func (a Application) DecreaseProductPrice(ctx context.Context, cmd commands.DecreaseProductPrice) (err error) {
+func (a Application) DecreaseProductPrice(ctx context.Context, cmd commands.DecreaseProductPrice) (version int, err error) {
 	a.logger.Info().Msg("--> Products.DecreaseProductPrice")
 	defer func() { a.logger.Info().Err(err).Msg("<-- Products.DecreaseProductPrice") }()
 	return a.App.DecreaseProductPrice(ctx, cmd)
 }
 
-func (a Application) RemoveProduct(ctx context.Context, cmd commands.RemoveProduct) (err error) {
+func (a Application) RemoveProduct(ctx context.Context, cmd commands.RemoveProduct) (version int, err error) {
 	a.logger.Info().Msg("--> Stores.RemoveProduct")
 	defer func() { a.logger.Info().Err(err).Msg("<-- Stores.RemoveProduct") }()
 	return a.App.RemoveProduct(ctx, cmd)
@@ -108,3 +108,15 @@ func (a Application) GetProduct(ctx context.Context, query queries.GetProduct) (
 	defer func() { a.logger.Info().Err(err).Msg("<-- Stores.GetProduct") }()
 	return a.App.GetProduct(ctx, query)
 }
+
+func (a Application) GetStoreHistory(ctx context.Context, query queries.GetStoreHistory) (events []*domain.HistoryEvent, err error) {
+	a.logger.Info().Msg("--> Stores.GetStoreHistory")
+	defer func() { a.logger.Info().Err(err).Msg("<-- Stores.GetStoreHistory") }()
+	return a.App.GetStoreHistory(ctx, query)
+}
+
+func (a Application) GetProductHistory(ctx context.Context, query queries.GetProductHistory) (events []*domain.HistoryEvent, err error) {
+	a.logger.Info().Msg("--> Stores.GetProductHistory")
+	defer func() { a.logger.Info().Err(err).Msg("<-- Stores.GetProductHistory") }()
+	return a.App.GetProductHistory(ctx, query)
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/v8tix/mallbots-stores/internal/application"
+	"github.com/v8tix/mallbots-stores/internal/application/commands"
+	"github.com/v8tix/mallbots-stores/internal/application/queries"
+	"github.com/v8tix/mallbots-stores/internal/domain"
+)
+
+type Application struct {
+	application.App
+}
+
+var _ application.App = (*Application)(nil)
+
+func InstrumentApplication(application application.App) Application {
+	return Application{
+		App: application,
+	}
+}
+
+func (a Application) CreateStore(ctx context.Context, cmd commands.CreateStore) (version int, err error) {
+	defer observe("CreateStore", time.Now(), &err)
+	return a.App.CreateStore(ctx, cmd)
+}
+
+func (a Application) EnableParticipation(ctx context.Context, cmd commands.EnableParticipation) (version int, err error) {
+	defer observe("EnableParticipation", time.Now(), &err)
+	return a.App.EnableParticipation(ctx, cmd)
+}
+
+func (a Application) DisableParticipation(ctx context.Context, cmd commands.DisableParticipation) (version int, err error) {
+	defer observe("DisableParticipation", time.Now(), &err)
+	return a.App.DisableParticipation(ctx, cmd)
+}
+
+func (a Application) RebrandStore(ctx context.Context, cmd commands.RebrandStore) (version int, err error) {
+	defer observe("RebrandStore", time.Now(), &err)
+	return a.App.RebrandStore(ctx, cmd)
+}
+
+func (a Application) AddProduct(ctx context.Context, cmd commands.AddProduct) (version int, err error) {
+	defer observe("AddProduct", time.Now(), &err)
+	return a.App.AddProduct(ctx, cmd)
+}
+
+func (a Application) RebrandProduct(ctx context.Context, cmd commands.RebrandProduct) (version int, err error) {
+	defer observe("RebrandProduct", time.Now(), &err)
+	return a.App.RebrandProduct(ctx, cmd)
+}
+
+func (a Application) IncreaseProductPrice(ctx context.Context, cmd commands.IncreaseProductPrice) (version int, err error) {
+	defer observe("IncreaseProductPrice", time.Now(), &err)
+	return a.App.IncreaseProductPrice(ctx, cmd)
+}
+
+func (a Application) DecreaseProductPrice(ctx context.Context, cmd commands.DecreaseProductPrice) (version int, err error) {
+	defer observe("DecreaseProductPrice", time.Now(), &err)
+	return a.App.DecreaseProductPrice(ctx, cmd)
+}
+
+func (a Application) RemoveProduct(ctx context.Context, cmd commands.RemoveProduct) (version int, err error) {
+	defer observe("RemoveProduct", time.Now(), &err)
+	return a.App.RemoveProduct(ctx, cmd)
+}
+
+func (a Application) GetStore(ctx context.Context, query queries.GetStore) (store *domain.MallStore, err error) {
+	defer observe("GetStore", time.Now(), &err)
+	return a.App.GetStore(ctx, query)
+}
+
+func (a Application) GetStores(ctx context.Context, query queries.GetStores) (stores []*domain.MallStore, err error) {
+	defer observe("GetStores", time.Now(), &err)
+	return a.App.GetStores(ctx, query)
+}
+
+func (a Application) GetParticipatingStores(ctx context.Context, query queries.GetParticipatingStores) (store []*domain.MallStore, err error) {
+	defer observe("GetParticipatingStores", time.Now(), &err)
+	return a.App.GetParticipatingStores(ctx, query)
+}
+
+func (a Application) GetCatalog(ctx context.Context, query queries.GetCatalog) (products []*domain.CatalogProduct, err error) {
+	defer observe("GetCatalog", time.Now(), &err)
+	return a.App.GetCatalog(ctx, query)
+}
+
+func (a Application) GetProduct(ctx context.Context, query queries.GetProduct) (product *domain.CatalogProduct, err error) {
+	defer observe("GetProduct", time.Now(), &err)
+	return a.App.GetProduct(ctx, query)
+}
+
+func (a Application) GetStoreHistory(ctx context.Context, query queries.GetStoreHistory) (events []*domain.HistoryEvent, err error) {
+	defer observe("GetStoreHistory", time.Now(), &err)
+	return a.App.GetStoreHistory(ctx, query)
+}
+
+func (a Application) GetProductHistory(ctx context.Context, query queries.GetProductHistory) (events []*domain.HistoryEvent, err error) {
+	defer observe("GetProductHistory", time.Now(), &err)
+	return a.App.GetProductHistory(ctx, query)
+}
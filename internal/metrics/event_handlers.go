@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/v8tix/eda/ddd"
+)
+
+type EventHandlers[T ddd.Event] struct {
+	ddd.EventHandler[T]
+	label string
+}
+
+var _ ddd.EventHandler[ddd.Event] = (*EventHandlers[ddd.Event])(nil)
+
+func InstrumentEventHandlers[T ddd.Event](handlers ddd.EventHandler[T], label string) EventHandlers[T] {
+	return EventHandlers[T]{
+		EventHandler: handlers,
+		label:        label,
+	}
+}
+
+func (h EventHandlers[T]) HandleEvent(ctx context.Context, event T) (err error) {
+	defer func() { handledEvents.WithLabelValues(h.label, event.EventName(), outcome(err)).Inc() }()
+	return h.EventHandler.HandleEvent(ctx, event)
+}
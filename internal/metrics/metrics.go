@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	rpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "stores_rpc_duration_seconds",
+		Help:    "The time taken to serve stores RPCs, by method and status code",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "code"})
+	applicationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "stores_application_duration_seconds",
+		Help:    "The time taken by the stores commands and queries, by method and outcome",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "outcome"})
+	handledEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "stores_event_handlers_total",
+		Help: "The number of events handled by the stores event handlers, by handler, event and outcome",
+	}, []string{"handler", "event", "outcome"})
+)
+
+// observe records the duration of an application call; it is deferred with
+// a pointer to the named error result
+func observe(method string, start time.Time, err *error) {
+	applicationDuration.WithLabelValues(method, outcome(*err)).Observe(time.Since(start).Seconds())
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"github.com/v8tix/mallbots-stores/internal/config"
+	"github.com/v8tix/mallbots-stores/internal/health"
+	"github.com/v8tix/mallbots-stores/internal/rpc"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/nats-io/nats.go"
@@ -13,9 +15,20 @@ import (
 	"github.com/v8tix/eda/waiter"
 )
 
+// DrainFunc finishes work that must be done before the service stops, such
+// as publishing what is left in an outbox
+type DrainFunc func(ctx context.Context) error
+
 type Microservice interface {
+	// AddDrain registers drains that run on shutdown once the web and RPC
+	// servers have stopped, and before the message stream is drained
+	AddDrain(fns ...DrainFunc)
+	// AdminMux serves the admin API on the admin address, apart from Mux
+	AdminMux() *chi.Mux
 	Config() config.AppConfig
 	DB() *sql.DB
+	Health() *health.Monitor
+	Interceptors() *rpc.Interceptors
 	JS() nats.JetStreamContext
 	Logger() zerolog.Logger
 	Mux() *chi.Mux
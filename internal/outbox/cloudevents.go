@@ -0,0 +1,169 @@
+package outbox
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/v8tix/eda/am"
+	"github.com/v8tix/eda/registry"
+)
+
+const (
+	cloudEventsSpecVersion  = "1.0"
+	cloudEventsHeaderPrefix = "ce-"
+	cloudEventsContentType  = "application/cloudevents+json"
+
+	jsonContentType     = "application/json"
+	protobufContentType = "application/protobuf"
+)
+
+// cloudEventsAttributes cannot be used as extension names
+var cloudEventsAttributes = map[string]struct{}{
+	"specversion": {}, "id": {}, "source": {}, "type": {}, "subject": {}, "time": {},
+	"datacontenttype": {}, "dataschema": {}, "data": {}, "data_base64": {},
+}
+
+// CloudEventsEncoder publishes outbox messages as CloudEvents using the NATS
+// protocol binding. The message id, event name, aggregate id and occurred-at
+// become the id, type, subject and time attributes; every metadata entry,
+// including the correlation metadata, becomes an extension attribute named
+// after its key without the characters CloudEvents does not allow, e.g.
+// aggregate_version becomes aggregateversion.
+//
+// Event payloads are rendered as JSON when the registry can build them as
+// protobuf messages and are published as protobuf otherwise.
+type CloudEventsEncoder struct {
+	structured bool
+	source     string
+	reg        registry.Registry
+}
+
+var _ Encoder = (*CloudEventsEncoder)(nil)
+
+func NewCloudEventsEncoder(structured bool, source string, reg registry.Registry) CloudEventsEncoder {
+	return CloudEventsEncoder{
+		structured: structured,
+		source:     source,
+		reg:        reg,
+	}
+}
+
+func (e CloudEventsEncoder) Encode(rawMsg am.RawMessage) (*nats.Msg, error) {
+	var eventData am.EventMessageData
+	if err := proto.Unmarshal(rawMsg.Data(), &eventData); err != nil {
+		return nil, err
+	}
+
+	attributes := map[string]any{
+		"specversion": cloudEventsSpecVersion,
+		"id":          rawMsg.ID(),
+		"source":      e.source,
+		"type":        rawMsg.MessageName(),
+	}
+	if occurredAt := eventData.GetOccurredAt(); occurredAt != nil {
+		attributes["time"] = occurredAt.AsTime().UTC().Format(time.RFC3339Nano)
+	}
+	for key, value := range eventData.GetMetadata().AsMap() {
+		name := extensionName(key)
+		if _, reserved := cloudEventsAttributes[name]; reserved || name == "" {
+			continue
+		}
+		attributes[name] = value
+	}
+	if aggregateID, ok := attributes[extensionName(AggregateIDMetadataKey)].(string); ok {
+		attributes["subject"] = aggregateID
+	}
+
+	data, contentType := e.data(rawMsg.MessageName(), eventData.GetPayload())
+	attributes["datacontenttype"] = contentType
+
+	msg := &nats.Msg{
+		Subject: rawMsg.Subject(),
+		Header:  nats.Header{},
+	}
+
+	if !e.structured {
+		for name, value := range attributes {
+			if name == "datacontenttype" {
+				msg.Header.Set("content-type", contentType)
+				continue
+			}
+			msg.Header.Set(cloudEventsHeaderPrefix+name, headerValue(value))
+		}
+		msg.Data = data
+
+		return msg, nil
+	}
+
+	if contentType == jsonContentType {
+		attributes["data"] = json.RawMessage(data)
+	} else {
+		attributes["data_base64"] = data // []byte is encoded as base64
+	}
+
+	body, err := json.Marshal(attributes)
+	if err != nil {
+		return nil, err
+	}
+	msg.Header.Set("content-type", cloudEventsContentType)
+	msg.Data = body
+
+	return msg, nil
+}
+
+// data renders the event payload as JSON, falling back to the protobuf
+// payload as it was published
+func (e CloudEventsEncoder) data(name string, payload []byte) ([]byte, string) {
+	if e.reg == nil {
+		return payload, protobufContentType
+	}
+
+	v, err := e.reg.Deserialize(name, payload)
+	if err != nil {
+		return payload, protobufContentType
+	}
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return payload, protobufContentType
+	}
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return payload, protobufContentType
+	}
+
+	return data, jsonContentType
+}
+
+// extensionName lowercases the key and drops everything but letters and
+// digits, as CloudEvents requires of attribute names
+func extensionName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, key)
+}
+
+func headerValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
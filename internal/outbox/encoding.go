@@ -0,0 +1,67 @@
+package outbox
+
+import (
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/v8tix/eda/am"
+	"github.com/v8tix/eda/jetstream"
+	"github.com/v8tix/eda/registry"
+)
+
+// Encoding selects the envelope outbox messages are published in
+type Encoding string
+
+const (
+	// EncodingStream wraps messages in jetstream.StreamMessage, the envelope
+	// eda subscribers expect
+	EncodingStream Encoding = "stream"
+	// EncodingCloudEventsBinary publishes the event data as the message body
+	// with the CloudEvents attributes in ce- headers
+	EncodingCloudEventsBinary Encoding = "cloudevents-binary"
+	// EncodingCloudEventsStructured publishes a CloudEvents JSON document
+	EncodingCloudEventsStructured Encoding = "cloudevents-structured"
+)
+
+type (
+	// Encoder turns an outbox message into the NATS message that is
+	// published for it
+	Encoder interface {
+		Encode(rawMsg am.RawMessage) (*nats.Msg, error)
+	}
+
+	StreamEncoder struct{}
+)
+
+var _ Encoder = (*StreamEncoder)(nil)
+
+// NewEncoder builds the encoder for encoding; source is the CloudEvents
+// source attribute and the registry is used to render event payloads as JSON
+func NewEncoder(encoding Encoding, source string, reg registry.Registry) Encoder {
+	switch encoding {
+	case EncodingCloudEventsBinary:
+		return NewCloudEventsEncoder(false, source, reg)
+	case EncodingCloudEventsStructured:
+		return NewCloudEventsEncoder(true, source, reg)
+	default:
+		return StreamEncoder{}
+	}
+}
+
+// Encode wraps the message the same way jetstream.Stream wraps it
+func (StreamEncoder) Encode(rawMsg am.RawMessage) (*nats.Msg, error) {
+	data, err := proto.Marshal(&jetstream.StreamMessage{
+		Id:   rawMsg.ID(),
+		Name: rawMsg.MessageName(),
+		Data: rawMsg.Data(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &nats.Msg{
+		Subject: rawMsg.Subject(),
+		Data:    data,
+		Header:  nats.Header{},
+	}, nil
+}
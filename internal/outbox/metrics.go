@@ -0,0 +1,71 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const backlogTimeout = 5 * time.Second
+
+var (
+	publishedMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "stores_outbox_published_total",
+		Help: "The number of outbox messages published and acknowledged by the stream",
+	})
+	failedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "stores_outbox_failures_total",
+		Help: "The number of failed outbox publish attempts, by whether the message is retried or dead-lettered",
+	}, []string{"outcome"})
+
+	pendingDesc = prometheus.NewDesc(
+		"stores_outbox_pending_messages",
+		"The number of outbox messages waiting to be published",
+		nil, nil,
+	)
+	deadLetteredDesc = prometheus.NewDesc(
+		"stores_outbox_dead_lettered_messages",
+		"The number of dead-lettered outbox messages",
+		nil, nil,
+	)
+)
+
+type (
+	BacklogCounter interface {
+		Backlog(ctx context.Context) (pending, deadLettered int, err error)
+	}
+
+	// BacklogCollector reports the size of the outbox backlog, counting it
+	// whenever it is scraped
+	BacklogCollector struct {
+		counter BacklogCounter
+	}
+)
+
+var _ prometheus.Collector = (*BacklogCollector)(nil)
+
+func NewBacklogCollector(counter BacklogCounter) BacklogCollector {
+	return BacklogCollector{counter: counter}
+}
+
+func (c BacklogCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- pendingDesc
+	descs <- deadLetteredDesc
+}
+
+func (c BacklogCollector) Collect(metrics chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), backlogTimeout)
+	defer cancel()
+
+	pending, deadLettered, err := c.counter.Backlog(ctx)
+	if err != nil {
+		metrics <- prometheus.NewInvalidMetric(pendingDesc, err)
+		metrics <- prometheus.NewInvalidMetric(deadLetteredDesc, err)
+		return
+	}
+
+	metrics <- prometheus.MustNewConstMetric(pendingDesc, prometheus.GaugeValue, float64(pending))
+	metrics <- prometheus.MustNewConstMetric(deadLetteredDesc, prometheus.GaugeValue, float64(deadLettered))
+}
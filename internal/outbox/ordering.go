@@ -0,0 +1,86 @@
+package outbox
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/v8tix/eda/am"
+	"github.com/v8tix/mallbots-stores/internal/correlation"
+)
+
+// Integration events carry the aggregate they were published for in their
+// metadata; the outbox publishes the messages of each aggregate in order
+const (
+	AggregateIDMetadataKey      = "aggregate_id"
+	AggregateNameMetadataKey    = "aggregate_name"
+	AggregateVersionMetadataKey = "aggregate_version"
+	// ReplayedMetadataKey marks republished events, whose aggregate versions
+	// are older than the ones consumers may already have seen
+	ReplayedMetadataKey = "replayed"
+)
+
+// JetStream headers carrying the aggregate and correlation ids of a message
+const (
+	AggregateIDHeader      = "Aggregate-Id"
+	AggregateNameHeader    = "Aggregate-Name"
+	AggregateVersionHeader = "Aggregate-Version"
+	CorrelationIDHeader    = "Correlation-Id"
+	CausationIDHeader      = "Causation-Id"
+	ReplayedHeader         = "Replayed"
+)
+
+// Partition identifies the aggregate of a message; messages with the same
+// key are published in the order they were saved
+type Partition struct {
+	Key              string
+	AggregateID      string
+	AggregateName    string
+	AggregateVersion int
+}
+
+// PartitionOf reads the partition of an event message from its metadata;
+// messages without aggregate metadata have the zero partition and are
+// published in no particular order
+func PartitionOf(msg am.RawMessage) Partition {
+	fields := metadataOf(msg)
+	aggregateID := fields[AggregateIDMetadataKey].GetStringValue()
+	if aggregateID == "" {
+		return Partition{}
+	}
+
+	aggregateName := fields[AggregateNameMetadataKey].GetStringValue()
+
+	return Partition{
+		Key:              fmt.Sprintf("%s:%s", aggregateName, aggregateID),
+		AggregateID:      aggregateID,
+		AggregateName:    aggregateName,
+		AggregateVersion: int(fields[AggregateVersionMetadataKey].GetNumberValue()),
+	}
+}
+
+// correlationOf reads the correlation ids of an event message from its
+// metadata
+func correlationOf(msg am.RawMessage) correlation.IDs {
+	fields := metadataOf(msg)
+
+	return correlation.IDs{
+		CorrelationID: fields[correlation.CorrelationIDKey].GetStringValue(),
+		CausationID:   fields[correlation.CausationIDKey].GetStringValue(),
+	}
+}
+
+// replayedOf reports whether an event message was republished by a replay
+func replayedOf(msg am.RawMessage) bool {
+	return metadataOf(msg)[ReplayedMetadataKey].GetBoolValue()
+}
+
+func metadataOf(msg am.RawMessage) map[string]*structpb.Value {
+	var data am.EventMessageData
+	if err := proto.Unmarshal(msg.Data(), &data); err != nil {
+		return nil
+	}
+
+	return data.GetMetadata().GetFields()
+}
@@ -0,0 +1,255 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/v8tix/eda/am"
+	"github.com/v8tix/eda/tm"
+)
+
+const (
+	defaultBatchSize       = 50
+	defaultPollingInterval = 5 * time.Second
+	markTimeout            = 5 * time.Second
+	relistenDelay          = 5 * time.Second
+
+	defaultMaxAttempts = 10
+	defaultBackoff     = time.Second
+	defaultMaxBackoff  = 5 * time.Minute
+)
+
+type (
+	// Store is the outbox store with failed delivery attempts
+	Store interface {
+		tm.OutboxStore
+		MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error
+		MarkDeadLettered(ctx context.Context, id string, cause error) error
+	}
+
+	// Listener sends a wakeup whenever messages are added to the outbox; it
+	// returns when ctx is done or the listener fails
+	Listener interface {
+		Listen(ctx context.Context, wakeups chan<- struct{}) error
+	}
+
+	// Config sets how many messages are published per batch and how often
+	// the outbox is polled when no wakeups arrive
+	Config struct {
+		BatchSize       int
+		PollingInterval time.Duration
+		Retries         RetryPolicy
+	}
+
+	// RetryPolicy decides when a message that failed to publish is tried
+	// again; the backoff doubles with each attempt up to MaxBackoff and the
+	// message is dead-lettered once it has failed MaxAttempts times
+	RetryPolicy struct {
+		MaxAttempts int
+		Backoff     time.Duration
+		MaxBackoff  time.Duration
+	}
+
+	// Processor publishes the unpublished outbox messages in the order they
+	// were saved and marks only those that were acknowledged as published. A
+	// message that fails to publish is retried after its backoff; the later
+	// messages of its aggregate wait for it, also once it is dead-lettered,
+	// while those of other aggregates are still published.
+	//
+	// The processor is woken by its listener when messages are added and
+	// polls the outbox as a fallback.
+	Processor struct {
+		publisher am.RawMessagePublisher
+		store     Store
+		listener  Listener
+		cfg       Config
+		logger    zerolog.Logger
+	}
+
+	attempter interface {
+		Attempts() int
+	}
+)
+
+var _ tm.OutboxProcessor = (*Processor)(nil)
+
+// NewProcessor builds a Processor; listener may be nil, in which case the
+// processor only polls
+func NewProcessor(publisher am.RawMessagePublisher, store Store, listener Listener, cfg Config, logger zerolog.Logger) Processor {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.PollingInterval <= 0 {
+		cfg.PollingInterval = defaultPollingInterval
+	}
+	if cfg.Retries.MaxAttempts <= 0 {
+		cfg.Retries.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.Retries.Backoff <= 0 {
+		cfg.Retries.Backoff = defaultBackoff
+	}
+	if cfg.Retries.MaxBackoff < cfg.Retries.Backoff {
+		cfg.Retries.MaxBackoff = defaultMaxBackoff
+	}
+
+	return Processor{
+		publisher: publisher,
+		store:     store,
+		listener:  listener,
+		cfg:       cfg,
+		logger:    logger,
+	}
+}
+
+func (p Processor) Start(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	wakeups := make(chan struct{}, 1)
+	if p.listener != nil {
+		go p.listen(ctx, wakeups)
+	}
+
+	for {
+		published, failed, err := p.processMessages(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		// poll again immediately while there is a backlog
+		if published > 0 && !failed {
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(p.cfg.PollingInterval)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wakeups:
+		case <-timer.C:
+		}
+	}
+}
+
+// Drain publishes the messages that are ready to be published until there
+// are none left, a message fails or ctx is done
+func (p Processor) Drain(ctx context.Context) (drained int, err error) {
+	for ctx.Err() == nil {
+		published, failed, err := p.processMessages(ctx)
+		drained += published
+		if err != nil || failed || published == 0 {
+			return drained, err
+		}
+	}
+
+	return drained, ctx.Err()
+}
+
+// listen keeps the listener running until ctx is done; while it is down the
+// processor falls back to polling
+func (p Processor) listen(ctx context.Context, wakeups chan<- struct{}) {
+	for {
+		err := p.listener.Listen(ctx, wakeups)
+		if ctx.Err() != nil {
+			return
+		}
+		p.logger.Warn().Err(err).Msgf("outbox listener stopped; listening again in %s", relistenDelay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(relistenDelay):
+		}
+	}
+}
+
+func (p Processor) processMessages(ctx context.Context) (published int, failed bool, err error) {
+	msgs, err := p.store.FindUnpublished(ctx, p.cfg.BatchSize)
+	if err != nil {
+		return 0, false, err
+	}
+
+	// the outcome of a publish is recorded even when shutting down
+	markCtx, cancel := context.WithTimeout(context.Background(), markTimeout)
+	defer cancel()
+
+	ids := make([]string, 0, len(msgs))
+	stopped := make(map[string]struct{})
+	for _, msg := range msgs {
+		key := PartitionOf(msg).Key
+		if _, exists := stopped[key]; exists {
+			continue
+		}
+
+		if err = p.publisher.Publish(ctx, msg.Subject(), msg); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			if err = p.recordFailure(markCtx, msg, err); err != nil {
+				return 0, true, err
+			}
+			failed = true
+			// messages without a partition are not ordered and do not stop
+			// the messages after them
+			if key != "" {
+				stopped[key] = struct{}{}
+			}
+			continue
+		}
+		ids = append(ids, msg.ID())
+	}
+
+	if len(ids) == 0 {
+		return 0, failed, nil
+	}
+
+	if err = p.store.MarkPublished(markCtx, ids...); err != nil {
+		return 0, failed, err
+	}
+	publishedMessages.Add(float64(len(ids)))
+
+	return len(ids), failed, nil
+}
+
+func (p Processor) recordFailure(ctx context.Context, msg am.RawMessage, cause error) error {
+	attempts := 1
+	if a, ok := msg.(attempter); ok {
+		attempts += a.Attempts()
+	}
+
+	if attempts >= p.cfg.Retries.MaxAttempts {
+		p.logger.Error().Err(cause).Msgf("outbox message %s failed to publish after %d attempts; dead-lettering it", msg.ID(), attempts)
+		failedMessages.WithLabelValues("dead_letter").Inc()
+		return p.store.MarkDeadLettered(ctx, msg.ID(), cause)
+	}
+
+	backoff := p.cfg.Retries.backoff(attempts)
+	p.logger.Warn().Err(cause).Msgf("outbox message %s was not acknowledged; retrying in %s", msg.ID(), backoff)
+	failedMessages.WithLabelValues("retry").Inc()
+
+	return p.store.MarkFailed(ctx, msg.ID(), cause, time.Now().Add(backoff))
+}
+
+func (r RetryPolicy) backoff(attempts int) time.Duration {
+	backoff := r.Backoff
+	for i := 1; i < attempts && backoff < r.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.MaxBackoff {
+		backoff = r.MaxBackoff
+	}
+
+	return backoff
+}
@@ -0,0 +1,177 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/v8tix/eda/am"
+)
+
+type outboxMessage struct {
+	id       string
+	data     []byte
+	attempts int
+}
+
+func (m outboxMessage) ID() string          { return m.id }
+func (m outboxMessage) Subject() string     { return "stores.Events" }
+func (m outboxMessage) MessageName() string { return "stores.StoreRebranded" }
+func (m outboxMessage) Data() []byte        { return m.data }
+func (m outboxMessage) Attempts() int       { return m.attempts }
+
+// message builds an outbox message of the aggregate; messages without an
+// aggregate id have no partition
+func message(t *testing.T, id, aggregateID string, attempts int) am.RawMessage {
+	t.Helper()
+
+	fields := map[string]any{}
+	if aggregateID != "" {
+		fields[AggregateIDMetadataKey] = aggregateID
+		fields[AggregateNameMetadataKey] = "stores.Store"
+	}
+	metadata, err := structpb.NewStruct(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := proto.Marshal(&am.EventMessageData{Metadata: metadata})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return outboxMessage{id: id, data: data, attempts: attempts}
+}
+
+// unackedPublisher fails to publish, as when no PubAck arrives, the messages
+// in unacked
+type unackedPublisher struct {
+	unacked   map[string]bool
+	attempted []string
+}
+
+func (p *unackedPublisher) Publish(_ context.Context, _ string, msg am.RawMessage) error {
+	p.attempted = append(p.attempted, msg.ID())
+	if p.unacked[msg.ID()] {
+		return fmt.Errorf("no PubAck for %s", msg.ID())
+	}
+	return nil
+}
+
+type recordingStore struct {
+	unpublished  []am.RawMessage
+	published    []string
+	failed       []string
+	deadLettered []string
+}
+
+func (s *recordingStore) Save(context.Context, am.RawMessage) error { return nil }
+
+func (s *recordingStore) FindUnpublished(context.Context, int) ([]am.RawMessage, error) {
+	return s.unpublished, nil
+}
+
+func (s *recordingStore) MarkPublished(_ context.Context, ids ...string) error {
+	s.published = append(s.published, ids...)
+	return nil
+}
+
+func (s *recordingStore) MarkFailed(_ context.Context, id string, _ error, _ time.Time) error {
+	s.failed = append(s.failed, id)
+	return nil
+}
+
+func (s *recordingStore) MarkDeadLettered(_ context.Context, id string, _ error) error {
+	s.deadLettered = append(s.deadLettered, id)
+	return nil
+}
+
+func TestProcessorMarksOnlyAcknowledgedMessages(t *testing.T) {
+	tests := map[string]struct {
+		messages         func(t *testing.T) []am.RawMessage
+		unacked          []string
+		wantAttempted    []string
+		wantPublished    []string
+		wantFailed       []string
+		wantDeadLettered []string
+	}{
+		"all acknowledged": {
+			messages: func(t *testing.T) []am.RawMessage {
+				return []am.RawMessage{message(t, "a1", "a", 0), message(t, "a2", "a", 0), message(t, "b1", "b", 0)}
+			},
+			wantAttempted: []string{"a1", "a2", "b1"},
+			wantPublished: []string{"a1", "a2", "b1"},
+		},
+		"unacknowledged message holds back its aggregate": {
+			messages: func(t *testing.T) []am.RawMessage {
+				return []am.RawMessage{message(t, "a1", "a", 0), message(t, "a2", "a", 0), message(t, "b1", "b", 0)}
+			},
+			unacked:       []string{"a1"},
+			wantAttempted: []string{"a1", "b1"},
+			wantPublished: []string{"b1"},
+			wantFailed:    []string{"a1"},
+		},
+		"unacknowledged message without a partition": {
+			messages: func(t *testing.T) []am.RawMessage {
+				return []am.RawMessage{message(t, "x1", "", 0), message(t, "x2", "", 0)}
+			},
+			unacked:       []string{"x1"},
+			wantAttempted: []string{"x1", "x2"},
+			wantPublished: []string{"x2"},
+			wantFailed:    []string{"x1"},
+		},
+		"unacknowledged on the last attempt": {
+			messages: func(t *testing.T) []am.RawMessage {
+				return []am.RawMessage{message(t, "a1", "a", 2), message(t, "a2", "a", 0)}
+			},
+			unacked:          []string{"a1"},
+			wantAttempted:    []string{"a1"},
+			wantDeadLettered: []string{"a1"},
+		},
+		"nothing acknowledged": {
+			messages: func(t *testing.T) []am.RawMessage {
+				return []am.RawMessage{message(t, "a1", "a", 0), message(t, "b1", "b", 0)}
+			},
+			unacked:       []string{"a1", "b1"},
+			wantAttempted: []string{"a1", "b1"},
+			wantFailed:    []string{"a1", "b1"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			publisher := &unackedPublisher{unacked: make(map[string]bool)}
+			for _, id := range tc.unacked {
+				publisher.unacked[id] = true
+			}
+			store := &recordingStore{unpublished: tc.messages(t)}
+
+			processor := NewProcessor(publisher, store, nil, Config{
+				Retries: RetryPolicy{MaxAttempts: 3},
+			}, zerolog.Nop())
+
+			if _, _, err := processor.processMessages(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, check := range []struct {
+				name      string
+				got, want []string
+			}{
+				{"attempted", publisher.attempted, tc.wantAttempted},
+				{"published", store.published, tc.wantPublished},
+				{"failed", store.failed, tc.wantFailed},
+				{"dead-lettered", store.deadLettered, tc.wantDeadLettered},
+			} {
+				if !reflect.DeepEqual(check.got, check.want) {
+					t.Errorf("%s = %v, want %v", check.name, check.got, check.want)
+				}
+			}
+		})
+	}
+}
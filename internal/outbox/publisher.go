@@ -0,0 +1,86 @@
+package outbox
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/v8tix/eda/am"
+	"github.com/v8tix/mallbots-stores/internal/tracing"
+)
+
+// Publisher publishes outbox messages to JetStream and waits for the PubAck;
+// a nil error means the stream has stored the message.
+//
+// Messages are encoded by the publisher's Encoder, by default the same way
+// jetstream.Stream wraps them, and carry their id as the JetStream message id
+// so that republishing after a failed mark is deduplicated by the stream.
+// Messages published for an aggregate carry its id, name and version in the
+// Aggregate-Id, Aggregate-Name and Aggregate-Version headers and their
+// correlation ids in the Correlation-Id and Causation-Id headers. Replayed
+// messages carry "Replayed: true" so that consumers enforcing the order of
+// aggregate versions accept them.
+//
+// The trace context saved with a message is continued by the publish span
+// and passed on in the W3C traceparent and tracestate headers.
+type Publisher struct {
+	js      nats.JetStreamContext
+	encoder Encoder
+}
+
+var _ am.RawMessagePublisher = (*Publisher)(nil)
+
+// NewPublisher builds a Publisher; a nil encoder publishes messages wrapped
+// in jetstream.StreamMessage
+func NewPublisher(js nats.JetStreamContext, encoder Encoder) Publisher {
+	if encoder == nil {
+		encoder = StreamEncoder{}
+	}
+
+	return Publisher{
+		js:      js,
+		encoder: encoder,
+	}
+}
+
+func (p Publisher) Publish(ctx context.Context, _ string, rawMsg am.RawMessage) (err error) {
+	propagator := otel.GetTextMapPropagator()
+	ctx = propagator.Extract(ctx, tracing.FieldsCarrier(metadataOf(rawMsg)))
+	ctx, span := tracing.Start(ctx, "Outbox.Publish("+rawMsg.MessageName()+")", trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(
+		attribute.String("messaging.system", "nats"),
+		attribute.String("messaging.destination", rawMsg.Subject()),
+		attribute.String("messaging.message_id", rawMsg.ID()),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	msg, err := p.encoder.Encode(rawMsg)
+	if err != nil {
+		return err
+	}
+	if msg.Header == nil {
+		msg.Header = nats.Header{}
+	}
+	propagator.Inject(ctx, tracing.HeaderCarrier(msg.Header))
+
+	if partition := PartitionOf(rawMsg); partition.Key != "" {
+		msg.Header.Set(AggregateIDHeader, partition.AggregateID)
+		msg.Header.Set(AggregateNameHeader, partition.AggregateName)
+		msg.Header.Set(AggregateVersionHeader, strconv.Itoa(partition.AggregateVersion))
+	}
+	if ids := correlationOf(rawMsg); ids.CorrelationID != "" {
+		msg.Header.Set(CorrelationIDHeader, ids.CorrelationID)
+		msg.Header.Set(CausationIDHeader, ids.CausationID)
+	}
+
+	if replayedOf(rawMsg) {
+		msg.Header.Set(ReplayedHeader, "true")
+	}
+
+	_, err = p.js.PublishMsg(msg, nats.MsgId(rawMsg.ID()), nats.Context(ctx))
+
+	return err
+}
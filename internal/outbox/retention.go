@@ -0,0 +1,104 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultRetention     = 7 * 24 * time.Hour
+	defaultPurgeInterval = time.Hour
+	purgeBatchSize       = 500
+)
+
+var purgedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "stores_outbox_purged_total",
+	Help: "The number of published outbox messages removed by the retention job",
+}, []string{"mode"})
+
+type (
+	Purger interface {
+		Purge(ctx context.Context, before time.Time, limit int, archiveTableName string) (int, error)
+	}
+
+	// RetentionConfig sets how long published messages are kept and how
+	// often the retention job runs; with an ArchiveTableName the messages are
+	// moved there instead of deleted
+	RetentionConfig struct {
+		Retention        time.Duration
+		Interval         time.Duration
+		ArchiveTableName string
+	}
+
+	// RetentionJob purges published messages from the outbox once they are
+	// older than the retention. It works in small batches, each its own
+	// statement, so that the processor is never kept waiting on the table.
+	RetentionJob struct {
+		purger Purger
+		cfg    RetentionConfig
+		logger zerolog.Logger
+	}
+)
+
+func NewRetentionJob(purger Purger, cfg RetentionConfig, logger zerolog.Logger) RetentionJob {
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultRetention
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultPurgeInterval
+	}
+
+	return RetentionJob{
+		purger: purger,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// Run purges the outbox every interval until ctx is done; it satisfies
+// waiter.WaitFunc. Failed purges are logged and tried again on the next run.
+func (j RetentionJob) Run(ctx context.Context) error {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.Purge(ctx); err != nil && ctx.Err() == nil {
+			j.logger.Error().Err(err).Msg("stores outbox retention failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes every message published before the retention
+func (j RetentionJob) Purge(ctx context.Context) (purged int, err error) {
+	mode := "deleted"
+	if j.cfg.ArchiveTableName != "" {
+		mode = "archived"
+	}
+
+	before := time.Now().Add(-j.cfg.Retention)
+	for {
+		var batch int
+		batch, err = j.purger.Purge(ctx, before, purgeBatchSize, j.cfg.ArchiveTableName)
+		purged += batch
+		purgedMessages.WithLabelValues(mode).Add(float64(batch))
+		if err != nil || batch < purgeBatchSize {
+			break
+		}
+	}
+
+	if purged > 0 {
+		j.logger.Info().Msgf("stores outbox retention %s %d messages published before %s", mode, purged, before.Format(time.RFC3339))
+	}
+
+	return purged, err
+}
@@ -0,0 +1,149 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	minRestartDelay = time.Second
+	maxRestartDelay = time.Minute
+	// a processor that ran this long before failing restarts without delay
+	healthyRunTime = time.Minute
+)
+
+const (
+	StateStarting   State = "starting"
+	StateRunning    State = "running"
+	StateRestarting State = "restarting"
+	StateStopping   State = "stopping"
+	StateDraining   State = "draining"
+	StateStopped    State = "stopped"
+)
+
+type (
+	State string
+
+	// Status is a snapshot of the supervised processor
+	Status struct {
+		State     State     `json:"state"`
+		Restarts  int       `json:"restarts"`
+		LastError string    `json:"last_error,omitempty"`
+		Since     time.Time `json:"since"`
+	}
+
+	// Supervisor runs the processor for the lifetime of the service,
+	// restarting it with a growing delay when it fails. The outbox is drained
+	// on shutdown by Drain, once nothing adds to it any longer.
+	Supervisor struct {
+		processor Processor
+		logger    zerolog.Logger
+		stopped   chan struct{}
+		mu        sync.RWMutex
+		status    Status
+	}
+)
+
+func NewSupervisor(processor Processor, logger zerolog.Logger) *Supervisor {
+	return &Supervisor{
+		processor: processor,
+		logger:    logger,
+		stopped:   make(chan struct{}),
+		status: Status{
+			State: StateStarting,
+			Since: time.Now(),
+		},
+	}
+}
+
+// Run runs the processor until ctx is done; it satisfies waiter.WaitFunc
+func (s *Supervisor) Run(ctx context.Context) error {
+	defer close(s.stopped)
+
+	delay := minRestartDelay
+	for {
+		s.setState(StateRunning, nil)
+		started := time.Now()
+		err := s.processor.Start(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		if err == nil {
+			// the processor only stops on its own when it fails
+			continue
+		}
+
+		s.setState(StateRestarting, err)
+
+		if time.Since(started) >= healthyRunTime {
+			delay = minRestartDelay
+		}
+		s.logger.Error().Err(err).Msgf("stores outbox processor encountered an error; restarting in %s", delay)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		if delay *= 2; delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+	}
+
+	s.setState(StateStopping, nil)
+
+	return nil
+}
+
+// Status returns the current status of the processor
+func (s *Supervisor) Status() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.status
+}
+
+// Drain publishes the ready messages until the outbox is empty or ctx is
+// done; it waits for Run to stop the processor first. It satisfies
+// ms.DrainFunc and is meant to run once the servers have stopped, so that
+// no request adds messages while the outbox is drained.
+func (s *Supervisor) Drain(ctx context.Context) error {
+	select {
+	case <-s.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	s.setState(StateDraining, nil)
+
+	drained, err := s.processor.Drain(ctx)
+	if err != nil {
+		s.logger.Error().Err(err).Msgf("stores outbox drained %d messages before stopping", drained)
+	} else {
+		s.logger.Info().Msgf("stores outbox drained %d messages", drained)
+	}
+
+	s.setState(StateStopped, err)
+
+	return err
+}
+
+func (s *Supervisor) setState(state State, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state == StateRestarting {
+		s.status.Restarts++
+	}
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	s.status.State = state
+	s.status.Since = time.Now()
+}
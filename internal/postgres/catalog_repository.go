@@ -66,6 +66,9 @@ func (r CatalogRepository) Find(ctx context.Context, productID string) (*domain.
 
 	err := r.db.QueryRowContext(ctx, r.table(query), productID).Scan(&product.StoreID, &product.Name, &product.Description, &product.SKU, &product.Price)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrProductNotFound
+		}
 		return nil, errors.Wrap(err, "scanning product")
 	}
 
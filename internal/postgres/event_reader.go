@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/stackus/errors"
+
+	"github.com/v8tix/eda/ddd"
+	"github.com/v8tix/eda/postgres"
+	"github.com/v8tix/eda/registry"
+)
+
+type (
+	// EventReader reads the events of every aggregate of a kind from the
+	// event store, in aggregate then version order
+	EventReader struct {
+		tableName string
+		db        postgres.DB
+		registry  registry.Registry
+	}
+
+	// EventPosition is the position of an event within an EventReader
+	// listing; the zero value is the position before the first event
+	EventPosition struct {
+		AggregateID string
+		Version     int
+	}
+
+	storedEvent struct {
+		id            string
+		name          string
+		payload       ddd.EventPayload
+		metadata      ddd.Metadata
+		occurredAt    time.Time
+		aggregateName string
+		aggregateID   string
+		version       int
+	}
+)
+
+var _ ddd.AggregateEvent = (*storedEvent)(nil)
+
+func NewEventReader(tableName string, db postgres.DB, registry registry.Registry) EventReader {
+	return EventReader{
+		tableName: tableName,
+		db:        db,
+		registry:  registry,
+	}
+}
+
+// ReadEvents returns up to limit events of the named aggregate that follow
+// the position; with aggregateIDs only the events of those aggregates are
+// returned
+func (r EventReader) ReadEvents(ctx context.Context, aggregateName string, after EventPosition, limit int, aggregateIDs ...string) (events []ddd.AggregateEvent, err error) {
+	const query = `SELECT stream_id, stream_version, event_id, event_name, event_data, metadata, occurred_at FROM %s
+WHERE stream_name = $1 AND (stream_id, stream_version) > ($2, $3)
+AND (cardinality($5::text[]) = 0 OR stream_id = ANY ($5))
+ORDER BY stream_id, stream_version LIMIT $4`
+
+	ids := &pgtype.TextArray{}
+	if err = ids.Set(append([]string{}, aggregateIDs...)); err != nil {
+		return nil, err
+	}
+
+	var rows *sql.Rows
+	rows, err = r.db.QueryContext(ctx, r.table(query), aggregateName, after.AggregateID, after.Version, limit, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying events")
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			err = errors.Wrap(err, "closing event rows")
+			fmt.Println(fmt.Errorf("%s", err))
+		}
+	}(rows)
+
+	for rows.Next() {
+		event := storedEvent{
+			aggregateName: aggregateName,
+		}
+		var data, metadata []byte
+		err := rows.Scan(&event.aggregateID, &event.version, &event.id, &event.name, &data, &metadata, &event.occurredAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning event")
+		}
+
+		event.payload, err = r.registry.Deserialize(event.name, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "deserializing event %s", event.name)
+		}
+		if event.metadata, err = unmarshalMetadata(metadata); err != nil {
+			return nil, errors.Wrapf(err, "unmarshalling event %s metadata", event.id)
+		}
+
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "finishing event rows")
+	}
+
+	return events, nil
+}
+
+// StreamVersions returns the current version of every stream of the named
+// aggregate, keyed by the aggregate id
+func (r EventReader) StreamVersions(ctx context.Context, aggregateName string) (versions map[string]int, err error) {
+	const query = `SELECT stream_id, max(stream_version) FROM %s WHERE stream_name = $1 GROUP BY stream_id`
+
+	var rows *sql.Rows
+	rows, err = r.db.QueryContext(ctx, r.table(query), aggregateName)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying stream versions")
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			err = errors.Wrap(err, "closing stream version rows")
+			fmt.Println(fmt.Errorf("%s", err))
+		}
+	}(rows)
+
+	versions = make(map[string]int)
+	for rows.Next() {
+		var id string
+		var version int
+		if err := rows.Scan(&id, &version); err != nil {
+			return nil, errors.Wrap(err, "scanning stream version")
+		}
+		versions[id] = version
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "finishing stream version rows")
+	}
+
+	return versions, nil
+}
+
+// PositionOf returns the position of the event within an EventReader listing
+func PositionOf(event ddd.AggregateEvent) EventPosition {
+	return EventPosition{
+		AggregateID: event.AggregateID(),
+		Version:     event.AggregateVersion(),
+	}
+}
+
+func (r EventReader) table(query string) string {
+	return fmt.Sprintf(query, r.tableName)
+}
+
+func (e storedEvent) ID() string                { return e.id }
+func (e storedEvent) EventName() string         { return e.name }
+func (e storedEvent) Payload() ddd.EventPayload { return e.payload }
+func (e storedEvent) Metadata() ddd.Metadata    { return e.metadata }
+func (e storedEvent) OccurredAt() time.Time     { return e.occurredAt }
+func (e storedEvent) AggregateName() string     { return e.aggregateName }
+func (e storedEvent) AggregateID() string       { return e.aggregateID }
+func (e storedEvent) AggregateVersion() int     { return e.version }
@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/stackus/errors"
+
+	"github.com/v8tix/eda/ddd"
+	"github.com/v8tix/eda/es"
+	"github.com/v8tix/eda/postgres"
+	"github.com/v8tix/eda/registry"
+)
+
+// EventStore replaces the eda event store, which drops event metadata, with
+// one that keeps it in the metadata column as JSON. The aggregate name, id
+// and version are stored in their own columns and are left out.
+type EventStore struct {
+	tableName string
+	db        postgres.DB
+	registry  registry.Registry
+}
+
+var _ es.AggregateStore = (*EventStore)(nil)
+
+func NewEventStore(tableName string, db postgres.DB, registry registry.Registry) EventStore {
+	return EventStore{
+		tableName: tableName,
+		db:        db,
+		registry:  registry,
+	}
+}
+
+func (s EventStore) Load(ctx context.Context, aggregate es.EventSourcedAggregate) (err error) {
+	const query = `SELECT stream_version, event_id, event_name, event_data, metadata, occurred_at FROM %s
+WHERE stream_id = $1 AND stream_name = $2 AND stream_version > $3
+ORDER BY stream_version ASC`
+
+	aggregateID := aggregate.ID()
+	aggregateName := aggregate.AggregateName()
+
+	var rows *sql.Rows
+	rows, err = s.db.QueryContext(ctx, s.table(query), aggregateID, aggregateName, aggregate.Version())
+	if err != nil {
+		return errors.Wrap(err, "querying events")
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			err = errors.Wrap(err, "closing event rows")
+			fmt.Println(fmt.Errorf("%s", err))
+		}
+	}(rows)
+
+	for rows.Next() {
+		event := storedEvent{
+			aggregateName: aggregateName,
+			aggregateID:   aggregateID,
+		}
+		var data, metadata []byte
+		err := rows.Scan(&event.version, &event.id, &event.name, &data, &metadata, &event.occurredAt)
+		if err != nil {
+			return errors.Wrap(err, "scanning event")
+		}
+
+		event.payload, err = s.registry.Deserialize(event.name, data)
+		if err != nil {
+			return errors.Wrapf(err, "deserializing event %s", event.name)
+		}
+		if event.metadata, err = unmarshalMetadata(metadata); err != nil {
+			return errors.Wrapf(err, "unmarshalling event %s metadata", event.id)
+		}
+
+		if err = es.LoadEvent(aggregate, event); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return errors.Wrap(err, "finishing event rows")
+	}
+
+	return nil
+}
+
+func (s EventStore) Save(ctx context.Context, aggregate es.EventSourcedAggregate) error {
+	const query = `INSERT INTO %s (stream_id, stream_name, stream_version, event_id, event_name, event_data, metadata, occurred_at) VALUES`
+	const columns = 8
+
+	events := aggregate.Events()
+	if len(events) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(events))
+	values := make([]any, 0, len(events)*columns)
+
+	for i, event := range events {
+		data, err := s.registry.Serialize(event.EventName(), event.Payload())
+		if err != nil {
+			return err
+		}
+		metadata, err := marshalMetadata(event.Metadata())
+		if err != nil {
+			return errors.Wrapf(err, "marshalling event %s metadata", event.ID())
+		}
+
+		params := make([]string, columns)
+		for j := range params {
+			params[j] = fmt.Sprintf("$%d", i*columns+j+1)
+		}
+		placeholders[i] = fmt.Sprintf("(%s)", strings.Join(params, ", "))
+
+		values = append(values,
+			aggregate.ID(),
+			aggregate.AggregateName(),
+			event.AggregateVersion(),
+			event.ID(),
+			event.EventName(),
+			data,
+			metadata,
+			event.OccurredAt(),
+		)
+	}
+
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf("%s %s", s.table(query), strings.Join(placeholders, ", ")), values...)
+
+	return err
+}
+
+func (s EventStore) table(query string) string {
+	return fmt.Sprintf(query, s.tableName)
+}
+
+// marshalMetadata returns the metadata that is not kept in its own column as
+// JSON; it is an empty object when there is none, as the column is NOT NULL
+// and a nil value would be written as NULL rather than the column default
+func marshalMetadata(metadata ddd.Metadata) ([]byte, error) {
+	stored := make(map[string]any, len(metadata))
+	for key, value := range metadata {
+		switch key {
+		case ddd.AggregateNameKey, ddd.AggregateIDKey, ddd.AggregateVersionKey:
+			continue
+		}
+		stored[key] = value
+	}
+	if len(stored) == 0 {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(stored)
+}
+
+func unmarshalMetadata(data []byte) (ddd.Metadata, error) {
+	metadata := ddd.Metadata{}
+	if len(data) == 0 {
+		return metadata, nil
+	}
+
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
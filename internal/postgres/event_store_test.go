@@ -0,0 +1,96 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/v8tix/eda/ddd"
+	"github.com/v8tix/eda/registry"
+	"github.com/v8tix/eda/registry/serdes"
+	"github.com/v8tix/mallbots-stores/internal/correlation"
+	"github.com/v8tix/mallbots-stores/internal/domain"
+	"github.com/v8tix/mallbots-stores/internal/postgres"
+)
+
+// execDB records the arguments of every statement executed through it
+type execDB struct {
+	*sql.DB
+	args [][]any
+}
+
+func (db *execDB) ExecContext(_ context.Context, _ string, args ...any) (sql.Result, error) {
+	db.args = append(db.args, args)
+	return driverResult(1), nil
+}
+
+type driverResult int64
+
+func (r driverResult) LastInsertId() (int64, error) { return 0, nil }
+func (r driverResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+func TestEventStoreSaveMetadata(t *testing.T) {
+	const metadataArg = 6
+
+	tests := map[string]struct {
+		metadata ddd.Metadata
+		want     map[string]any
+	}{
+		"empty": {
+			metadata: ddd.Metadata{},
+			want:     map[string]any{},
+		},
+		"correlation ids": {
+			metadata: correlation.IDs{CorrelationID: "correlation-1", CausationID: "request-1"}.Metadata(),
+			want: map[string]any{
+				correlation.CorrelationIDKey: "correlation-1",
+				correlation.CausationIDKey:   "request-1",
+			},
+		},
+	}
+
+	reg := registry.New()
+	if err := serdes.NewJsonSerde(reg).Register(domain.StoreCreated{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			store, err := domain.CreateStore("store-1", "Store", "Mall")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, event := range store.Events() {
+				for key, value := range tc.metadata {
+					event.Metadata().Set(key, value)
+				}
+			}
+
+			db := &execDB{}
+			if err = postgres.NewEventStore("stores.events", db, reg).Save(context.Background(), store); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(db.args) != 1 {
+				t.Fatalf("executed %d statements, want 1", len(db.args))
+			}
+			data, ok := db.args[0][metadataArg].([]byte)
+			if !ok || data == nil {
+				t.Fatalf("metadata = %#v, want JSON; a nil value is written as NULL", db.args[0][metadataArg])
+			}
+			var got map[string]any
+			if err = json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("metadata = %s, want %v", data, tc.want)
+			}
+			for key, value := range tc.want {
+				if got[key] != value {
+					t.Errorf("metadata[%q] = %v, want %v", key, got[key], value)
+				}
+			}
+		})
+	}
+}
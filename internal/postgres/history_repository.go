@@ -0,0 +1,183 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/stackus/errors"
+
+	"github.com/v8tix/eda/es"
+	"github.com/v8tix/eda/postgres"
+	"github.com/v8tix/eda/registry"
+	"github.com/v8tix/mallbots-stores/internal/domain"
+)
+
+type HistoryRepository struct {
+	tableName         string
+	snapshotTableName string
+	db                postgres.DB
+	registry          registry.Registry
+}
+
+var _ domain.HistoryRepository = (*HistoryRepository)(nil)
+
+func NewHistoryRepository(tableName, snapshotTableName string, db postgres.DB, registry registry.Registry) HistoryRepository {
+	return HistoryRepository{
+		tableName:         tableName,
+		snapshotTableName: snapshotTableName,
+		db:                db,
+		registry:          registry,
+	}
+}
+
+func (r HistoryRepository) History(ctx context.Context, aggregateName, aggregateID string, afterVersion, limit int) (events []*domain.HistoryEvent, err error) {
+	const query = `SELECT stream_version, event_id, event_name, event_data, metadata, occurred_at FROM %s
+WHERE stream_id = $1 AND stream_name = $2 AND stream_version > $3
+ORDER BY stream_version ASC LIMIT $4`
+
+	var rows *sql.Rows
+	rows, err = r.db.QueryContext(ctx, r.table(query), aggregateID, aggregateName, afterVersion, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying history")
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			err = errors.Wrap(err, "closing history rows")
+			fmt.Println(fmt.Errorf("%s", err))
+		}
+	}(rows)
+
+	for rows.Next() {
+		event := new(domain.HistoryEvent)
+		var data, metadata []byte
+		err := rows.Scan(&event.Version, &event.ID, &event.Name, &data, &metadata, &event.OccurredAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning history event")
+		}
+
+		event.Payload, err = r.registry.Deserialize(event.Name, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "deserializing history event %s", event.Name)
+		}
+		if event.Metadata, err = unmarshalMetadata(metadata); err != nil {
+			return nil, errors.Wrapf(err, "unmarshalling history event %s metadata", event.ID)
+		}
+
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "finishing history rows")
+	}
+
+	return events, nil
+}
+
+func (r HistoryRepository) Version(ctx context.Context, aggregateName, aggregateID string) (version int, err error) {
+	const query = `SELECT COALESCE(MAX(stream_version), 0) FROM %s WHERE stream_id = $1 AND stream_name = $2`
+
+	err = r.db.QueryRowContext(ctx, r.table(query), aggregateID, aggregateName).Scan(&version)
+	if err != nil {
+		return 0, errors.Wrap(err, "scanning aggregate version")
+	}
+
+	return version, nil
+}
+
+func (r HistoryRepository) LoadAsOf(ctx context.Context, aggregate es.EventSourcedAggregate, asOf time.Time, version int) (err error) {
+	const versionQuery = `SELECT COALESCE(MAX(stream_version), 0) FROM %s
+WHERE stream_id = $1 AND stream_name = $2 AND ($3::timestamptz IS NULL OR occurred_at <= $3) AND ($4 = 0 OR stream_version <= $4)`
+	const snapshotQuery = `SELECT stream_version, snapshot_name, snapshot_data FROM %s
+WHERE stream_id = $1 AND stream_name = $2 AND stream_version <= $3 LIMIT 1`
+	const eventsQuery = `SELECT stream_version, event_id, event_name, event_data, metadata, occurred_at FROM %s
+WHERE stream_id = $1 AND stream_name = $2 AND stream_version > $3 AND stream_version <= $4
+ORDER BY stream_version ASC`
+
+	aggregateID := aggregate.ID()
+	aggregateName := aggregate.AggregateName()
+
+	var until any
+	if !asOf.IsZero() {
+		until = asOf
+	}
+
+	var targetVersion int
+	err = r.db.QueryRowContext(ctx, r.table(versionQuery), aggregateID, aggregateName, until, version).Scan(&targetVersion)
+	if err != nil {
+		return errors.Wrap(err, "scanning target version")
+	}
+	if targetVersion == 0 {
+		return nil
+	}
+
+	// the snapshot store only keeps the latest snapshot; it is only of use
+	// when it does not go beyond the target
+	var snapshotVersion int
+	var snapshotName string
+	var snapshotData []byte
+	err = r.db.QueryRowContext(ctx, r.snapshotTable(snapshotQuery), aggregateID, aggregateName, targetVersion).Scan(&snapshotVersion, &snapshotName, &snapshotData)
+	switch {
+	case err == nil:
+		// a stale snapshot is skipped and the full stream replayed instead
+		if loadSnapshot(r.registry, aggregate, snapshotName, snapshotData, snapshotVersion) != nil {
+			recordLoad(aggregateName, "stale")
+		}
+	case errors.Is(err, sql.ErrNoRows):
+	default:
+		return errors.Wrap(err, "scanning snapshot")
+	}
+
+	var rows *sql.Rows
+	rows, err = r.db.QueryContext(ctx, r.table(eventsQuery), aggregateID, aggregateName, aggregate.Version(), targetVersion)
+	if err != nil {
+		return errors.Wrap(err, "querying events")
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			err = errors.Wrap(err, "closing event rows")
+			fmt.Println(fmt.Errorf("%s", err))
+		}
+	}(rows)
+
+	for rows.Next() {
+		event := storedEvent{
+			aggregateName: aggregateName,
+			aggregateID:   aggregateID,
+		}
+		var data, metadata []byte
+		err := rows.Scan(&event.version, &event.id, &event.name, &data, &metadata, &event.occurredAt)
+		if err != nil {
+			return errors.Wrap(err, "scanning event")
+		}
+
+		event.payload, err = r.registry.Deserialize(event.name, data)
+		if err != nil {
+			return errors.Wrapf(err, "deserializing event %s", event.name)
+		}
+		if event.metadata, err = unmarshalMetadata(metadata); err != nil {
+			return errors.Wrapf(err, "unmarshalling event %s metadata", event.id)
+		}
+
+		if err = es.LoadEvent(aggregate, event); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return errors.Wrap(err, "finishing event rows")
+	}
+
+	return nil
+}
+
+func (r HistoryRepository) table(query string) string {
+	return fmt.Sprintf(query, r.tableName)
+}
+
+func (r HistoryRepository) snapshotTable(query string) string {
+	return fmt.Sprintf(query, r.snapshotTableName)
+}
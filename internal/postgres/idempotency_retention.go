@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultIdempotencyPurgeInterval = time.Hour
+	idempotencyPurgeBatchSize       = 500
+)
+
+var purgedIdempotencyKeys = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "stores_idempotency_keys_purged_total",
+	Help: "The number of expired idempotency keys removed by the retention job",
+})
+
+type (
+	IdempotencyPurger interface {
+		Purge(ctx context.Context, limit int) (int, error)
+	}
+
+	// IdempotencyRetentionJob deletes the idempotency keys that have outlived
+	// their retention window, in small batches like the outbox retention job
+	IdempotencyRetentionJob struct {
+		purger   IdempotencyPurger
+		interval time.Duration
+		logger   zerolog.Logger
+	}
+)
+
+func NewIdempotencyRetentionJob(purger IdempotencyPurger, interval time.Duration, logger zerolog.Logger) IdempotencyRetentionJob {
+	if interval <= 0 {
+		interval = defaultIdempotencyPurgeInterval
+	}
+
+	return IdempotencyRetentionJob{
+		purger:   purger,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run purges the expired keys every interval until ctx is done; it satisfies
+// waiter.WaitFunc. Failed purges are logged and tried again on the next run.
+func (j IdempotencyRetentionJob) Run(ctx context.Context) error {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.Purge(ctx); err != nil && ctx.Err() == nil {
+			j.logger.Error().Err(err).Msg("stores idempotency key retention failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes every expired key
+func (j IdempotencyRetentionJob) Purge(ctx context.Context) (purged int, err error) {
+	for {
+		var batch int
+		batch, err = j.purger.Purge(ctx, idempotencyPurgeBatchSize)
+		purged += batch
+		purgedIdempotencyKeys.Add(float64(batch))
+		if err != nil || batch < idempotencyPurgeBatchSize {
+			break
+		}
+	}
+
+	if purged > 0 {
+		j.logger.Info().Msgf("stores idempotency key retention deleted %d expired keys", purged)
+	}
+
+	return purged, err
+}
@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/stackus/errors"
+
+	"github.com/v8tix/eda/postgres"
+)
+
+const defaultIdempotencyRetention = 24 * time.Hour
+
+var ErrIdempotencyKeyInUse = errors.Wrap(errors.ErrConflict, "the idempotency key is being used by another request")
+
+// IdempotencyStore keeps the responses of requests submitted with an
+// idempotency key; rows are keyed by (key, method) and hold a hash of the
+// request, the serialized response and the version of the aggregate the
+// request left behind, along with the time it was created
+type IdempotencyStore struct {
+	tableName string
+	db        postgres.DB
+	retention time.Duration
+}
+
+func NewIdempotencyStore(tableName string, db postgres.DB, retention time.Duration) IdempotencyStore {
+	if retention <= 0 {
+		retention = defaultIdempotencyRetention
+	}
+
+	return IdempotencyStore{
+		tableName: tableName,
+		db:        db,
+		retention: retention,
+	}
+}
+
+// Find returns the request hash, response and aggregate version recorded for
+// the key within the retention window or nils when there are none
+func (s IdempotencyStore) Find(ctx context.Context, key, method string) (requestHash, response []byte, aggregateVersion int, err error) {
+	const query = "SELECT request_hash, response, aggregate_version FROM %s WHERE key = $1 AND method = $2 AND created_at > $3"
+
+	err = s.db.QueryRowContext(ctx, s.table(query), key, method, s.cutoff()).Scan(&requestHash, &response, &aggregateVersion)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, 0, nil
+		}
+		return nil, nil, 0, errors.Wrap(err, "scanning idempotent response")
+	}
+
+	return requestHash, response, aggregateVersion, nil
+}
+
+// Save records the response for the key; expired keys are reused while a key
+// saved by a concurrent request results in ErrIdempotencyKeyInUse
+func (s IdempotencyStore) Save(ctx context.Context, key, method string, requestHash, response []byte, aggregateVersion int) error {
+	const query = `INSERT INTO %[1]s (key, method, request_hash, response, aggregate_version, created_at) VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+ON CONFLICT (key, method) DO
+UPDATE SET request_hash = EXCLUDED.request_hash, response = EXCLUDED.response, aggregate_version = EXCLUDED.aggregate_version, created_at = EXCLUDED.created_at
+WHERE %[1]s.created_at <= $6`
+
+	result, err := s.db.ExecContext(ctx, s.table(query), key, method, requestHash, response, aggregateVersion, s.cutoff())
+	if err != nil {
+		return errors.Wrap(err, "saving idempotent response")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "saving idempotent response")
+	}
+	if rows == 0 {
+		return ErrIdempotencyKeyInUse
+	}
+
+	return nil
+}
+
+// Purge deletes up to limit keys that have outlived the retention window.
+// Rows locked by other transactions are skipped rather than waited for.
+func (s IdempotencyStore) Purge(ctx context.Context, limit int) (int, error) {
+	const query = `DELETE FROM %[1]s WHERE (key, method) IN (
+SELECT key, method FROM %[1]s WHERE created_at <= $1 ORDER BY created_at LIMIT $2 FOR UPDATE SKIP LOCKED
+)`
+
+	result, err := s.db.ExecContext(ctx, s.table(query), s.cutoff(), limit)
+	if err != nil {
+		return 0, errors.Wrap(err, "purging idempotency keys")
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(purged), nil
+}
+
+func (s IdempotencyStore) cutoff() time.Time {
+	return time.Now().Add(-s.retention)
+}
+
+func (s IdempotencyStore) table(query string) string {
+	return fmt.Sprintf(query, s.tableName)
+}
@@ -58,6 +58,9 @@ func (r MallRepository) Find(ctx context.Context, storeID string) (*domain.MallS
 
 	err := r.db.QueryRowContext(ctx, r.table(query), storeID).Scan(&store.Name, &store.Location, &store.Participating)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrStoreNotFound
+		}
 		return nil, errors.Wrap(err, "scanning store")
 	}
 
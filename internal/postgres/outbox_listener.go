@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stackus/errors"
+)
+
+// OutboxListener listens on the channel the OutboxStore notifies when it
+// saves messages. It holds its own connection, outside the pool, for as long
+// as it listens.
+type OutboxListener struct {
+	connString string
+	channel    string
+}
+
+func NewOutboxListener(connString, channel string) OutboxListener {
+	return OutboxListener{
+		connString: connString,
+		channel:    channel,
+	}
+}
+
+func (l OutboxListener) Listen(ctx context.Context, wakeups chan<- struct{}) error {
+	conn, err := pgx.Connect(ctx, l.connString)
+	if err != nil {
+		return errors.Wrap(err, "connecting the outbox listener")
+	}
+	defer func() {
+		_ = conn.Close(context.Background())
+	}()
+
+	if _, err = conn.Exec(ctx, fmt.Sprintf("LISTEN %s", pgx.Identifier{l.channel}.Sanitize())); err != nil {
+		return errors.Wrap(err, "listening for outbox messages")
+	}
+
+	// wake once in case messages were saved while not listening
+	wake(wakeups)
+
+	for {
+		if _, err = conn.WaitForNotification(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrap(err, "waiting for outbox messages")
+		}
+		wake(wakeups)
+	}
+}
+
+func wake(wakeups chan<- struct{}) {
+	select {
+	case wakeups <- struct{}{}:
+	default:
+		// a wakeup is already pending
+	}
+}
@@ -0,0 +1,312 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgtype"
+	"github.com/stackus/errors"
+
+	"github.com/v8tix/eda/am"
+	"github.com/v8tix/eda/postgres"
+	"github.com/v8tix/eda/tm"
+	"github.com/v8tix/mallbots-stores/internal/outbox"
+)
+
+var ErrDeadLetterNotFound = errors.Wrap(errors.ErrNotFound, "the dead-lettered message does not exist")
+
+type (
+	// OutboxStore extends the eda outbox with delivery attempts; besides id,
+	// name, subject, data and published_at the table has the columns
+	// attempts, last_error, next_attempt_at and dead_lettered_at.
+	//
+	// Messages are kept in the order they were saved by the bigserial seq
+	// column and are partitioned by the aggregate they were published for
+	// with the partition_key and aggregate_version columns.
+	//
+	// Saving a message notifies the channel, when one is set, once the
+	// transaction commits.
+	OutboxStore struct {
+		tableName string
+		channel   string
+		db        postgres.DB
+	}
+
+	OutboxMessage struct {
+		id       string
+		name     string
+		subject  string
+		data     []byte
+		attempts int
+	}
+
+	DeadLetter struct {
+		ID             string
+		Name           string
+		Subject        string
+		Attempts       int
+		LastError      string
+		DeadLetteredAt time.Time
+	}
+)
+
+var _ tm.OutboxStore = (*OutboxStore)(nil)
+var _ am.RawMessage = (*OutboxMessage)(nil)
+
+func NewOutboxStore(tableName, channel string, db postgres.DB) OutboxStore {
+	return OutboxStore{
+		tableName: tableName,
+		channel:   channel,
+		db:        db,
+	}
+}
+
+func (s OutboxStore) Save(ctx context.Context, msg am.RawMessage) error {
+	const query = "INSERT INTO %s (id, name, subject, data, partition_key, aggregate_version) VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, 0))"
+
+	partition := outbox.PartitionOf(msg)
+
+	_, err := s.db.ExecContext(ctx, s.table(query), msg.ID(), msg.MessageName(), msg.Subject(), msg.Data(), partition.Key, partition.AggregateVersion)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			if pgErr.Code == pgerrcode.UniqueViolation {
+				return tm.ErrDuplicateMessage(msg.ID())
+			}
+		}
+		return err
+	}
+
+	if s.channel == "" {
+		return nil
+	}
+
+	// notifications are delivered on commit and duplicates within a
+	// transaction are folded into one
+	_, err = s.db.ExecContext(ctx, "SELECT pg_notify($1, '')", s.channel)
+
+	return err
+}
+
+// FindUnpublished returns, in the order they were saved, the messages that
+// are neither published nor dead-lettered and whose backoff has passed.
+//
+// A message is held back while an earlier message of its partition is
+// waiting out a backoff or is dead-lettered so that an aggregate's messages
+// are never published out of order; a dead-lettered message holds its
+// partition back until it is requeued and published.
+func (s OutboxStore) FindUnpublished(ctx context.Context, limit int) (msgs []am.RawMessage, err error) {
+	const query = `SELECT id, name, subject, data, attempts FROM %[1]s m
+WHERE published_at IS NULL AND dead_lettered_at IS NULL AND (next_attempt_at IS NULL OR next_attempt_at <= CURRENT_TIMESTAMP)
+AND (partition_key IS NULL OR NOT EXISTS (
+SELECT 1 FROM %[1]s p
+WHERE p.partition_key = m.partition_key AND p.seq < m.seq
+AND p.published_at IS NULL AND (p.dead_lettered_at IS NOT NULL OR p.next_attempt_at > CURRENT_TIMESTAMP)
+))
+ORDER BY seq
+LIMIT $1`
+
+	var rows *sql.Rows
+	rows, err = s.db.QueryContext(ctx, s.table(query), limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying outbox messages")
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			err = errors.Wrap(err, "closing outbox rows")
+			fmt.Println(fmt.Errorf("%s", err))
+		}
+	}(rows)
+
+	for rows.Next() {
+		msg := OutboxMessage{}
+		err := rows.Scan(&msg.id, &msg.name, &msg.subject, &msg.data, &msg.attempts)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning outbox message")
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "finishing outbox rows")
+	}
+
+	return msgs, nil
+}
+
+func (s OutboxStore) MarkPublished(ctx context.Context, ids ...string) error {
+	const query = "UPDATE %s SET published_at = CURRENT_TIMESTAMP WHERE id = ANY ($1)"
+
+	msgIDs := &pgtype.TextArray{}
+	err := msgIDs.Set(ids)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, s.table(query), msgIDs)
+
+	return err
+}
+
+// MarkFailed records a failed delivery attempt; the message is retried
+// after retryAt
+func (s OutboxStore) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	const query = `UPDATE %s SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1`
+
+	_, err := s.db.ExecContext(ctx, s.table(query), id, cause.Error(), retryAt)
+
+	return err
+}
+
+// MarkDeadLettered records a final failed delivery attempt; the message is
+// not retried until it is requeued
+func (s OutboxStore) MarkDeadLettered(ctx context.Context, id string, cause error) error {
+	const query = `UPDATE %s SET attempts = attempts + 1, last_error = $2, next_attempt_at = NULL, dead_lettered_at = CURRENT_TIMESTAMP WHERE id = $1`
+
+	_, err := s.db.ExecContext(ctx, s.table(query), id, cause.Error())
+
+	return err
+}
+
+// Backlog counts the messages waiting to be published and the
+// dead-lettered messages
+func (s OutboxStore) Backlog(ctx context.Context) (pending, deadLettered int, err error) {
+	const query = `SELECT COUNT(*) FILTER (WHERE dead_lettered_at IS NULL), COUNT(*) FILTER (WHERE dead_lettered_at IS NOT NULL) FROM %s
+WHERE published_at IS NULL`
+
+	err = s.db.QueryRowContext(ctx, s.table(query)).Scan(&pending, &deadLettered)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "counting outbox backlog")
+	}
+
+	return pending, deadLettered, nil
+}
+
+func (s OutboxStore) FindDeadLetters(ctx context.Context, limit int) (deadLetters []*DeadLetter, err error) {
+	const query = `SELECT id, name, subject, attempts, COALESCE(last_error, ''), dead_lettered_at FROM %s
+WHERE dead_lettered_at IS NOT NULL
+ORDER BY dead_lettered_at, id
+LIMIT $1`
+
+	var rows *sql.Rows
+	rows, err = s.db.QueryContext(ctx, s.table(query), limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying dead letters")
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			err = errors.Wrap(err, "closing dead letter rows")
+			fmt.Println(fmt.Errorf("%s", err))
+		}
+	}(rows)
+
+	for rows.Next() {
+		deadLetter := &DeadLetter{}
+		err := rows.Scan(&deadLetter.ID, &deadLetter.Name, &deadLetter.Subject, &deadLetter.Attempts, &deadLetter.LastError, &deadLetter.DeadLetteredAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning dead letter")
+		}
+
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "finishing dead letter rows")
+	}
+
+	return deadLetters, nil
+}
+
+// Requeue returns dead-lettered messages to the outbox with a fresh set of
+// attempts; without ids every dead-lettered message is requeued
+func (s OutboxStore) Requeue(ctx context.Context, ids ...string) (int, error) {
+	const query = `UPDATE %s SET attempts = 0, last_error = NULL, next_attempt_at = NULL, dead_lettered_at = NULL
+WHERE dead_lettered_at IS NOT NULL AND (cardinality($1::text[]) = 0 OR id = ANY ($1))`
+
+	msgIDs := &pgtype.TextArray{}
+	if err := msgIDs.Set(append([]string{}, ids...)); err != nil {
+		return 0, err
+	}
+
+	result, err := s.db.ExecContext(ctx, s.table(query), msgIDs)
+	if err != nil {
+		return 0, errors.Wrap(err, "requeuing dead letters")
+	}
+
+	requeued, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ids) != 0 && requeued == 0 {
+		return 0, ErrDeadLetterNotFound
+	}
+
+	return int(requeued), nil
+}
+
+// Purge deletes up to limit messages published before the given time,
+// copying them into archiveTableName first when it is set. Rows locked by
+// other transactions are skipped rather than waited for.
+func (s OutboxStore) Purge(ctx context.Context, before time.Time, limit int, archiveTableName string) (int, error) {
+	const deleteQuery = `DELETE FROM %[1]s WHERE id IN (
+SELECT id FROM %[1]s WHERE published_at < $1 ORDER BY published_at LIMIT $2 FOR UPDATE SKIP LOCKED
+)`
+	const archiveQuery = `WITH purged AS (
+DELETE FROM %[1]s WHERE id IN (
+SELECT id FROM %[1]s WHERE published_at < $1 ORDER BY published_at LIMIT $2 FOR UPDATE SKIP LOCKED
+)
+RETURNING id, name, subject, data, published_at
+)
+INSERT INTO %[2]s (id, name, subject, data, published_at)
+SELECT id, name, subject, data, published_at FROM purged`
+
+	query := fmt.Sprintf(deleteQuery, s.tableName)
+	if archiveTableName != "" {
+		query = fmt.Sprintf(archiveQuery, s.tableName, archiveTableName)
+	}
+
+	result, err := s.db.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, errors.Wrap(err, "purging outbox messages")
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(purged), nil
+}
+
+func (s OutboxStore) table(query string) string {
+	return fmt.Sprintf(query, s.tableName)
+}
+
+func (m OutboxMessage) ID() string {
+	return m.id
+}
+
+func (m OutboxMessage) Subject() string {
+	return m.subject
+}
+
+func (m OutboxMessage) MessageName() string {
+	return m.name
+}
+
+func (m OutboxMessage) Data() []byte {
+	return m.data
+}
+
+// Attempts returns the number of failed delivery attempts
+func (m OutboxMessage) Attempts() int {
+	return m.attempts
+}
@@ -0,0 +1,137 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"reflect"
+	"testing"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+
+	"github.com/v8tix/mallbots-stores/internal/postgres"
+)
+
+// testDatabaseEnv names the database the outbox queries are tested against;
+// the tests are skipped when it is not set
+const testDatabaseEnv = "STORES_TEST_PG_URI"
+
+// outboxSchema is the outbox table of the mallbots database scripts with the
+// columns added by the migrations
+const outboxSchema = `
+DROP SCHEMA IF EXISTS outbox_test CASCADE;
+CREATE SCHEMA outbox_test;
+
+CREATE TABLE outbox_test.outbox
+(
+  id                text        NOT NULL PRIMARY KEY,
+  name              text        NOT NULL,
+  subject           text        NOT NULL,
+  data              bytea       NOT NULL,
+  published_at      timestamptz,
+  attempts          int         NOT NULL DEFAULT 0,
+  last_error        text,
+  next_attempt_at   timestamptz,
+  dead_lettered_at  timestamptz,
+  seq               bigserial   NOT NULL,
+  partition_key     text,
+  aggregate_version int
+);
+`
+
+// outboxRow is saved in the order rows are listed; the state is one of
+// "", "published", "backoff", "due" or "dead-lettered"
+type outboxRow struct {
+	id        string
+	partition string
+	state     string
+}
+
+func TestOutboxStoreFindUnpublished(t *testing.T) {
+	uri := os.Getenv(testDatabaseEnv)
+	if uri == "" {
+		t.Skipf("%s is not set", testDatabaseEnv)
+	}
+
+	ctx := context.Background()
+	db, err := sql.Open("pgx", uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err = db.ExecContext(ctx, outboxSchema); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = db.ExecContext(ctx, "DROP SCHEMA outbox_test CASCADE")
+	}()
+
+	tests := map[string]struct {
+		rows []outboxRow
+		want []string
+	}{
+		"saved order across partitions": {
+			rows: []outboxRow{{"a1", "a", ""}, {"b1", "b", ""}, {"a2", "a", ""}},
+			want: []string{"a1", "b1", "a2"},
+		},
+		"backoff holds back its partition": {
+			rows: []outboxRow{{"a1", "a", "backoff"}, {"a2", "a", ""}, {"b1", "b", ""}},
+			want: []string{"b1"},
+		},
+		"due retry is published in order": {
+			rows: []outboxRow{{"a1", "a", "due"}, {"a2", "a", ""}},
+			want: []string{"a1", "a2"},
+		},
+		"dead letter holds back its partition": {
+			rows: []outboxRow{{"a1", "a", "dead-lettered"}, {"a2", "a", ""}, {"b1", "b", ""}},
+			want: []string{"b1"},
+		},
+		"later backoff does not hold back earlier messages": {
+			rows: []outboxRow{{"a1", "a", ""}, {"a2", "a", "backoff"}, {"a3", "a", ""}},
+			want: []string{"a1"},
+		},
+		"messages without a partition are not held back": {
+			rows: []outboxRow{{"x1", "", "backoff"}, {"x2", "", ""}},
+			want: []string{"x2"},
+		},
+		"published messages are skipped": {
+			rows: []outboxRow{{"a1", "a", "published"}, {"a2", "a", ""}},
+			want: []string{"a2"},
+		},
+	}
+
+	const insert = `INSERT INTO outbox_test.outbox (id, name, subject, data, partition_key, published_at, next_attempt_at, dead_lettered_at)
+VALUES ($1, 'stores.StoreRebranded', 'stores.Events', '', NULLIF($2, ''),
+CASE WHEN $3 = 'published' THEN CURRENT_TIMESTAMP END,
+CASE $3 WHEN 'backoff' THEN CURRENT_TIMESTAMP + interval '1 hour' WHEN 'due' THEN CURRENT_TIMESTAMP - interval '1 second' END,
+CASE WHEN $3 = 'dead-lettered' THEN CURRENT_TIMESTAMP END)`
+
+	store := postgres.NewOutboxStore("outbox_test.outbox", "", db)
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := db.ExecContext(ctx, "TRUNCATE outbox_test.outbox"); err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range tc.rows {
+				if _, err := db.ExecContext(ctx, insert, row.id, row.partition, row.state); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			msgs, err := store.FindUnpublished(ctx, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, msg := range msgs {
+				got = append(got, msg.ID())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unpublished = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
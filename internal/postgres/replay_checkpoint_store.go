@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/stackus/errors"
+
+	"github.com/v8tix/eda/postgres"
+)
+
+// ReplayCheckpointStore keeps the position of each replay per aggregate so
+// that an interrupted replay resumes where it stopped; rows are keyed by
+// (name, aggregate_name)
+type ReplayCheckpointStore struct {
+	tableName string
+	db        postgres.DB
+}
+
+func NewReplayCheckpointStore(tableName string, db postgres.DB) ReplayCheckpointStore {
+	return ReplayCheckpointStore{
+		tableName: tableName,
+		db:        db,
+	}
+}
+
+// Find returns the position reached by the replay and the number of events
+// it has replayed; a replay that has not started is at the zero position
+func (s ReplayCheckpointStore) Find(ctx context.Context, name, aggregateName string) (position EventPosition, replayed int, err error) {
+	const query = `SELECT stream_id, stream_version, replayed FROM %s WHERE name = $1 AND aggregate_name = $2`
+
+	err = s.db.QueryRowContext(ctx, s.table(query), name, aggregateName).Scan(&position.AggregateID, &position.Version, &replayed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return EventPosition{}, 0, nil
+		}
+		return EventPosition{}, 0, errors.Wrap(err, "scanning replay checkpoint")
+	}
+
+	return position, replayed, nil
+}
+
+func (s ReplayCheckpointStore) Save(ctx context.Context, name, aggregateName string, position EventPosition, replayed int) error {
+	const query = `INSERT INTO %s (name, aggregate_name, stream_id, stream_version, replayed, updated_at)
+VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+ON CONFLICT (name, aggregate_name) DO
+UPDATE SET stream_id = EXCLUDED.stream_id, stream_version = EXCLUDED.stream_version, replayed = EXCLUDED.replayed, updated_at = EXCLUDED.updated_at`
+
+	_, err := s.db.ExecContext(ctx, s.table(query), name, aggregateName, position.AggregateID, position.Version, replayed)
+
+	return err
+}
+
+// Delete forgets the replay so that it starts over
+func (s ReplayCheckpointStore) Delete(ctx context.Context, name string) error {
+	const query = `DELETE FROM %s WHERE name = $1`
+
+	_, err := s.db.ExecContext(ctx, s.table(query), name)
+
+	return err
+}
+
+func (s ReplayCheckpointStore) table(query string) string {
+	return fmt.Sprintf(query, s.tableName)
+}
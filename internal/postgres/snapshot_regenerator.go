@@ -0,0 +1,203 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/rs/zerolog"
+	"github.com/stackus/errors"
+
+	"github.com/v8tix/eda/ddd"
+	"github.com/v8tix/eda/es"
+	"github.com/v8tix/eda/registry"
+)
+
+const regenerateBatchSize = 100
+
+var ErrUnknownAggregate = errors.Wrap(errors.ErrNotFound, "the aggregate does not exist")
+
+// SnapshotRegenerator invalidates or regenerates the snapshots of an
+// aggregate after its model has changed
+type SnapshotRegenerator struct {
+	tableName       string
+	eventsTableName string
+	db              *sql.DB
+	registry        registry.Registry
+	aggregateNames  map[string]struct{}
+	logger          zerolog.Logger
+}
+
+func NewSnapshotRegenerator(tableName, eventsTableName string, db *sql.DB, registry registry.Registry, logger zerolog.Logger, aggregateNames ...string) SnapshotRegenerator {
+	r := SnapshotRegenerator{
+		tableName:       tableName,
+		eventsTableName: eventsTableName,
+		db:              db,
+		registry:        registry,
+		aggregateNames:  make(map[string]struct{}, len(aggregateNames)),
+		logger:          logger,
+	}
+
+	for _, aggregateName := range aggregateNames {
+		r.aggregateNames[aggregateName] = struct{}{}
+	}
+
+	return r
+}
+
+// Invalidate deletes the snapshots of the aggregate; loads replay the full
+// streams until new snapshots are taken
+func (r SnapshotRegenerator) Invalidate(ctx context.Context, aggregateName string) (int, error) {
+	const query = `DELETE FROM %s WHERE stream_name = $1`
+
+	if _, exists := r.aggregateNames[aggregateName]; !exists {
+		return 0, ErrUnknownAggregate
+	}
+
+	result, err := r.db.ExecContext(ctx, r.table(query), aggregateName)
+	if err != nil {
+		return 0, errors.Wrap(err, "deleting snapshots")
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	r.logger.Info().Msgf("invalidated %d %s snapshots", deleted, aggregateName)
+
+	return int(deleted), nil
+}
+
+// Regenerate writes a snapshot in the current snapshot version for every
+// stream of the aggregate, built by replaying the full stream; it works the
+// same whether or not the snapshots were invalidated first.
+//
+// Each batch of snapshots is written in its own transaction; a snapshot that
+// was moved ahead by a concurrent command is left as it is.
+func (r SnapshotRegenerator) Regenerate(ctx context.Context, aggregateName string) (regenerated int, err error) {
+	if _, exists := r.aggregateNames[aggregateName]; !exists {
+		return 0, ErrUnknownAggregate
+	}
+
+	r.logger.Info().Msgf("regenerating %s snapshots", aggregateName)
+
+	var afterID string
+	for {
+		var ids []string
+		ids, err = r.streamIDs(ctx, aggregateName, afterID)
+		if err != nil {
+			return regenerated, err
+		}
+
+		for _, id := range ids {
+			if err = r.regenerate(ctx, aggregateName, id); err != nil {
+				return regenerated, errors.Wrapf(err, "regenerating %s snapshot %s", aggregateName, id)
+			}
+			regenerated++
+		}
+
+		if len(ids) < regenerateBatchSize {
+			break
+		}
+		afterID = ids[len(ids)-1]
+	}
+
+	r.logger.Info().Msgf("regenerated %d %s snapshots", regenerated, aggregateName)
+
+	return regenerated, nil
+}
+
+func (r SnapshotRegenerator) streamIDs(ctx context.Context, aggregateName, afterID string) (ids []string, err error) {
+	const query = `SELECT DISTINCT stream_id FROM %s WHERE stream_name = $1 AND stream_id > $2 ORDER BY stream_id LIMIT $3`
+
+	var rows *sql.Rows
+	rows, err = r.db.QueryContext(ctx, r.eventsTable(query), aggregateName, afterID, regenerateBatchSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying streams")
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			err = errors.Wrap(err, "closing stream rows")
+			fmt.Println(fmt.Errorf("%s", err))
+		}
+	}(rows)
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "scanning stream")
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "finishing stream rows")
+	}
+
+	return ids, nil
+}
+
+func (r SnapshotRegenerator) regenerate(ctx context.Context, aggregateName, id string) (err error) {
+	const query = `INSERT INTO %s AS s (stream_id, stream_name, stream_version, snapshot_name, snapshot_data)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (stream_id, stream_name) DO
+UPDATE SET stream_version = EXCLUDED.stream_version, snapshot_name = EXCLUDED.snapshot_name, snapshot_data = EXCLUDED.snapshot_data
+WHERE s.stream_version <= EXCLUDED.stream_version`
+
+	var tx *sql.Tx
+	tx, err = r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	var v any
+	v, err = r.registry.Build(aggregateName, ddd.SetID(id), ddd.SetName(aggregateName))
+	if err != nil {
+		return err
+	}
+
+	aggregate, ok := v.(interface {
+		es.EventSourcedAggregate
+		es.Snapshotter
+	})
+	if !ok {
+		return fmt.Errorf("%T does not implelement es.Snapshotter", v)
+	}
+
+	if err = NewEventStore(r.eventsTableName, tx, r.registry).Load(ctx, aggregate); err != nil {
+		return err
+	}
+
+	if aggregate.Version() == 0 {
+		return nil
+	}
+
+	snapshot := aggregate.ToSnapshot()
+
+	var data []byte
+	data, err = r.registry.Serialize(snapshot.SnapshotName(), snapshot)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, r.table(query), id, aggregateName, aggregate.Version(), snapshot.SnapshotName(), data)
+
+	return err
+}
+
+func (r SnapshotRegenerator) table(query string) string {
+	return fmt.Sprintf(query, r.tableName)
+}
+
+func (r SnapshotRegenerator) eventsTable(query string) string {
+	return fmt.Sprintf(query, r.eventsTableName)
+}
@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/stackus/errors"
+
+	"github.com/v8tix/eda/es"
+	"github.com/v8tix/eda/postgres"
+	"github.com/v8tix/eda/registry"
+)
+
+var (
+	snapshotLoads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "stores_aggregate_loads_total",
+		Help: "The number of aggregates loaded, by whether they started from a snapshot or replayed their full stream",
+	}, []string{"aggregate", "source"})
+	snapshotsSaved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "stores_snapshots_saved_total",
+		Help: "The number of aggregate snapshots saved",
+	}, []string{"aggregate"})
+)
+
+// SnapshotStore replaces the eda snapshot store, which snapshots every third
+// event, with one that asks the strategy configured for each aggregate
+type SnapshotStore struct {
+	es.AggregateStore
+	tableName       string
+	eventsTableName string
+	db              postgres.DB
+	registry        registry.Registry
+	strategies      SnapshotStrategies
+}
+
+var _ es.AggregateStore = (*SnapshotStore)(nil)
+
+func NewSnapshotStore(tableName, eventsTableName string, db postgres.DB, registry registry.Registry, strategies SnapshotStrategies) es.AggregateStoreMiddleware {
+	snapshots := SnapshotStore{
+		tableName:       tableName,
+		eventsTableName: eventsTableName,
+		db:              db,
+		registry:        registry,
+		strategies:      strategies,
+	}
+
+	return func(store es.AggregateStore) es.AggregateStore {
+		snapshots.AggregateStore = store
+		return snapshots
+	}
+}
+
+func (s SnapshotStore) Load(ctx context.Context, aggregate es.EventSourcedAggregate) error {
+	const query = `SELECT stream_version, snapshot_name, snapshot_data FROM %s WHERE stream_id = $1 AND stream_name = $2 LIMIT 1`
+
+	var entityVersion int
+	var snapshotName string
+	var snapshotData []byte
+
+	if err := s.db.QueryRowContext(ctx, s.table(query), aggregate.ID(), aggregate.AggregateName()).Scan(&entityVersion, &snapshotName, &snapshotData); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			recordLoad(aggregate.AggregateName(), "replay")
+			return s.AggregateStore.Load(ctx, aggregate)
+		}
+		return err
+	}
+
+	if err := loadSnapshot(s.registry, aggregate, snapshotName, snapshotData, entityVersion); err != nil {
+		// a stale snapshot must not block loads; the stream has everything
+		recordLoad(aggregate.AggregateName(), "stale")
+		return s.AggregateStore.Load(ctx, aggregate)
+	}
+
+	recordLoad(aggregate.AggregateName(), "snapshot")
+
+	return s.AggregateStore.Load(ctx, aggregate)
+}
+
+func (s SnapshotStore) Save(ctx context.Context, aggregate es.EventSourcedAggregate) error {
+	const query = `INSERT INTO %s (stream_id, stream_name, stream_version, snapshot_name, snapshot_data)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (stream_id, stream_name) DO
+UPDATE SET stream_version = EXCLUDED.stream_version, snapshot_name = EXCLUDED.snapshot_name, snapshot_data = EXCLUDED.snapshot_data`
+
+	if err := s.AggregateStore.Save(ctx, aggregate); err != nil {
+		return err
+	}
+
+	ok, err := s.strategies.For(aggregate.AggregateName()).ShouldSnapshot(ctx, aggregate, s.lastSnapshot(aggregate))
+	if err != nil || !ok {
+		return err
+	}
+
+	sser, ok := aggregate.(es.Snapshotter)
+	if !ok {
+		return fmt.Errorf("%T does not implelement es.Snapshotter", aggregate)
+	}
+
+	snapshot := sser.ToSnapshot()
+
+	data, err := s.registry.Serialize(snapshot.SnapshotName(), snapshot)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, s.table(query), aggregate.ID(), aggregate.AggregateName(), aggregate.PendingVersion(), snapshot.SnapshotName(), data)
+	if err != nil {
+		return err
+	}
+
+	snapshotsSaved.WithLabelValues(aggregate.AggregateName()).Inc()
+
+	return nil
+}
+
+func (s SnapshotStore) lastSnapshot(aggregate es.EventSourcedAggregate) LastSnapshotFunc {
+	const query = `SELECT COALESCE(s.stream_version, 0), e.occurred_at FROM %s e
+LEFT JOIN %s s ON s.stream_id = e.stream_id AND s.stream_name = e.stream_name
+WHERE e.stream_id = $1 AND e.stream_name = $2 AND e.stream_version = COALESCE(s.stream_version, 1)`
+
+	return func(ctx context.Context) (info SnapshotInfo, err error) {
+		err = s.db.QueryRowContext(ctx, fmt.Sprintf(query, s.eventsTableName, s.tableName), aggregate.ID(), aggregate.AggregateName()).Scan(&info.Version, &info.TakenAt)
+		if err != nil {
+			return info, errors.Wrap(err, "scanning last snapshot")
+		}
+		return info, nil
+	}
+}
+
+// loadSnapshot applies the stored snapshot to the aggregate, upgrading older
+// snapshot versions on the way; it fails for snapshots that are no longer
+// registered or that the aggregate can no longer apply
+func loadSnapshot(reg registry.Registry, aggregate es.EventSourcedAggregate, snapshotName string, snapshotData []byte, version int) error {
+	v, err := reg.Deserialize(snapshotName, snapshotData, registry.ValidateImplements((*es.Snapshot)(nil)))
+	if err != nil {
+		return err
+	}
+
+	return es.LoadSnapshot(aggregate, v.(es.Snapshot), version)
+}
+
+// recordLoad counts an aggregate load by whether it started from a snapshot
+// or replayed the full stream; the hit ratio is derived from these counts
+func recordLoad(aggregateName, source string) {
+	snapshotLoads.WithLabelValues(aggregateName, source).Inc()
+}
+
+func (s SnapshotStore) table(query string) string {
+	return fmt.Sprintf(query, s.tableName)
+}
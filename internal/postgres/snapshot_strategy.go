@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/v8tix/eda/es"
+)
+
+type (
+	// SnapshotInfo describes the latest snapshot of an aggregate; TakenAt is
+	// the time of the event the snapshot was taken at, or of the first event
+	// when the aggregate has no snapshot
+	SnapshotInfo struct {
+		Version int
+		TakenAt time.Time
+	}
+
+	// LastSnapshotFunc looks up the latest snapshot of the aggregate being
+	// saved; strategies that do not need it never pay for the query
+	LastSnapshotFunc func(ctx context.Context) (SnapshotInfo, error)
+
+	SnapshotStrategy interface {
+		ShouldSnapshot(ctx context.Context, aggregate es.EventSourcedAggregate, last LastSnapshotFunc) (bool, error)
+	}
+
+	// SnapshotStrategies holds the strategy to use for each aggregate name;
+	// aggregates without a strategy are not snapshotted
+	SnapshotStrategies map[string]SnapshotStrategy
+
+	eventsStrategy struct {
+		maxChanges int
+	}
+
+	intervalStrategy struct {
+		interval time.Duration
+	}
+
+	disabledStrategy struct{}
+)
+
+// EveryNEvents snapshots an aggregate each time its version crosses a
+// multiple of maxChanges
+func EveryNEvents(maxChanges int) SnapshotStrategy {
+	return eventsStrategy{maxChanges: maxChanges}
+}
+
+// EveryInterval snapshots an aggregate when its latest snapshot, or its
+// first event, is older than the interval
+func EveryInterval(interval time.Duration) SnapshotStrategy {
+	return intervalStrategy{interval: interval}
+}
+
+// NoSnapshots never snapshots an aggregate
+func NoSnapshots() SnapshotStrategy {
+	return disabledStrategy{}
+}
+
+func (s SnapshotStrategies) For(aggregateName string) SnapshotStrategy {
+	if strategy, exists := s[aggregateName]; exists {
+		return strategy
+	}
+	return disabledStrategy{}
+}
+
+func (s eventsStrategy) ShouldSnapshot(_ context.Context, aggregate es.EventSourcedAggregate, _ LastSnapshotFunc) (bool, error) {
+	var pendingVersion = aggregate.PendingVersion()
+	var pendingChanges = len(aggregate.Events())
+
+	return pendingVersion >= s.maxChanges && ((pendingChanges >= s.maxChanges) ||
+		(pendingVersion%s.maxChanges < pendingChanges) ||
+		(pendingVersion%s.maxChanges == 0)), nil
+}
+
+func (s intervalStrategy) ShouldSnapshot(ctx context.Context, _ es.EventSourcedAggregate, last LastSnapshotFunc) (bool, error) {
+	info, err := last(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return time.Since(info.TakenAt) >= s.interval, nil
+}
+
+func (disabledStrategy) ShouldSnapshot(context.Context, es.EventSourcedAggregate, LastSnapshotFunc) (bool, error) {
+	return false, nil
+}
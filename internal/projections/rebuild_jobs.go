@@ -0,0 +1,128 @@
+package projections
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stackus/errors"
+)
+
+// maxFinishedJobs bounds how many finished rebuilds are remembered
+const maxFinishedJobs = 100
+
+const (
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+)
+
+var (
+	ErrUnknownRebuild = errors.Wrap(errors.ErrNotFound, "the rebuild does not exist")
+	ErrRebuildRunning = errors.Wrap(errors.ErrConflict, "the projection is already being rebuilt")
+)
+
+type (
+	JobState string
+
+	// RebuildJob is the progress of a rebuild started by RebuildJobs
+	RebuildJob struct {
+		ID         string     `json:"id"`
+		Projection string     `json:"projection"`
+		State      JobState   `json:"state"`
+		Replayed   int        `json:"replayed"`
+		Error      string     `json:"error,omitempty"`
+		StartedAt  time.Time  `json:"started_at"`
+		FinishedAt *time.Time `json:"finished_at,omitempty"`
+	}
+
+	// RebuildJobs runs rebuilds in the background so that their progress can
+	// be polled; a projection is rebuilt by one job at a time. Rebuilds are
+	// stopped when ctx is done, and the jobs are only kept in memory.
+	RebuildJobs struct {
+		ctx       context.Context
+		rebuilder Rebuilder
+
+		mu       sync.RWMutex
+		jobs     map[string]*RebuildJob
+		finished []string
+	}
+)
+
+func NewRebuildJobs(ctx context.Context, rebuilder Rebuilder) *RebuildJobs {
+	return &RebuildJobs{
+		ctx:       ctx,
+		rebuilder: rebuilder,
+		jobs:      make(map[string]*RebuildJob),
+	}
+}
+
+// Start starts rebuilding the projection and returns the new job
+func (j *RebuildJobs) Start(name string) (RebuildJob, error) {
+	if _, exists := j.rebuilder.projections[name]; !exists {
+		return RebuildJob{}, ErrUnknownProjection
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, job := range j.jobs {
+		if job.Projection == name && job.State == JobRunning {
+			return RebuildJob{}, ErrRebuildRunning
+		}
+	}
+
+	job := &RebuildJob{
+		ID:         uuid.New().String(),
+		Projection: name,
+		State:      JobRunning,
+		StartedAt:  time.Now(),
+	}
+	j.jobs[job.ID] = job
+
+	go j.run(job.ID, name)
+
+	return *job, nil
+}
+
+// Get returns the progress of the job
+func (j *RebuildJobs) Get(id string) (RebuildJob, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	job, exists := j.jobs[id]
+	if !exists {
+		return RebuildJob{}, ErrUnknownRebuild
+	}
+
+	return *job, nil
+}
+
+func (j *RebuildJobs) run(id, name string) {
+	replayed, err := j.rebuilder.rebuild(j.ctx, name, func(replayed int) {
+		j.mu.Lock()
+		j.jobs[id].Replayed = replayed
+		j.mu.Unlock()
+	})
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job := j.jobs[id]
+	job.Replayed = replayed
+	job.State = JobSucceeded
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	if err != nil {
+		job.State = JobFailed
+		job.Error = err.Error()
+		j.rebuilder.logger.Error().Err(err).Msgf("rebuilding projection %s failed", name)
+	}
+
+	j.finished = append(j.finished, id)
+	if len(j.finished) > maxFinishedJobs {
+		delete(j.jobs, j.finished[0])
+		j.finished = j.finished[1:]
+	}
+}
@@ -0,0 +1,272 @@
+package projections
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/stackus/errors"
+
+	"github.com/v8tix/eda/ddd"
+	"github.com/v8tix/eda/registry"
+	"github.com/v8tix/mallbots-stores/internal/postgres"
+)
+
+const (
+	batchSize        = 250
+	progressInterval = 1000
+)
+
+var ErrUnknownProjection = errors.Wrap(errors.ErrNotFound, "the projection does not exist")
+
+type (
+	// HandlersFactory builds the event handlers of a projection writing into
+	// the given table
+	HandlersFactory func(tableName string, tx *sql.Tx) ddd.EventHandler[ddd.AggregateEvent]
+
+	Projection struct {
+		Name           string
+		TableName      string
+		AggregateNames []string
+		NewHandlers    HandlersFactory
+	}
+
+	Rebuilder struct {
+		db          *sql.DB
+		eventsTable string
+		registry    registry.Registry
+		projections map[string]Projection
+		logger      zerolog.Logger
+	}
+)
+
+func NewRebuilder(db *sql.DB, eventsTable string, registry registry.Registry, logger zerolog.Logger, projections ...Projection) Rebuilder {
+	r := Rebuilder{
+		db:          db,
+		eventsTable: eventsTable,
+		registry:    registry,
+		projections: make(map[string]Projection, len(projections)),
+		logger:      logger,
+	}
+
+	for _, projection := range projections {
+		r.projections[projection.Name] = projection
+	}
+
+	return r
+}
+
+// Names returns the names of the projections that can be rebuilt
+func (r Rebuilder) Names() []string {
+	names := make([]string, 0, len(r.projections))
+	for name := range r.projections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Rebuild replays the events of the projection's aggregates into a shadow copy
+// of its table and then copies the result into the live table.
+//
+// The shadow is filled without locking the live table. Writers to the live
+// table are only blocked at the end, while the events saved during the
+// rebuild are replayed and the live table is refilled; readers are never
+// blocked. The live table is refilled in place, so its triggers, grants,
+// foreign keys and dependent views are kept. On error the live table is left
+// untouched. The number of events replayed is returned.
+func (r Rebuilder) Rebuild(ctx context.Context, name string) (replayed int, err error) {
+	return r.rebuild(ctx, name, func(int) {})
+}
+
+// streamVersions are the last replayed versions of the streams of each
+// aggregate, keyed by aggregate name and then id
+type streamVersions map[string]map[string]int
+
+// rebuild reports the number of events replayed so far to progress after
+// every event
+func (r Rebuilder) rebuild(ctx context.Context, name string, progress func(replayed int)) (replayed int, err error) {
+	projection, exists := r.projections[name]
+	if !exists {
+		return 0, ErrUnknownProjection
+	}
+
+	schema, table := splitTableName(projection.TableName)
+	shadowTable := qualify(schema, fmt.Sprintf("%s_rebuild", table))
+
+	replay := func(ctx context.Context, handlers ddd.EventHandler[ddd.AggregateEvent], event ddd.AggregateEvent) error {
+		if err := handlers.HandleEvent(ctx, event); err != nil {
+			return errors.Wrapf(err, "replaying %s event %s", event.EventName(), event.ID())
+		}
+		replayed++
+		progress(replayed)
+		if replayed%progressInterval == 0 {
+			r.logger.Info().Msgf("rebuilding projection %s: %d events replayed", name, replayed)
+		}
+		return nil
+	}
+
+	r.logger.Info().Msgf("rebuilding projection %s into %s", name, shadowTable)
+
+	versions := make(streamVersions, len(projection.AggregateNames))
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		statements := []string{
+			fmt.Sprintf("DROP TABLE IF EXISTS %s", shadowTable),
+			fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING ALL)", shadowTable, projection.TableName),
+		}
+		if err := exec(ctx, tx, statements...); err != nil {
+			return errors.Wrapf(err, "preparing projection %s", name)
+		}
+
+		handlers := projection.NewHandlers(shadowTable, tx)
+		events := postgres.NewEventReader(r.eventsTable, tx, r.registry)
+
+		for _, aggregateName := range projection.AggregateNames {
+			versions[aggregateName] = make(map[string]int)
+			var position postgres.EventPosition
+			for {
+				batch, err := events.ReadEvents(ctx, aggregateName, position, batchSize)
+				if err != nil {
+					return err
+				}
+
+				for _, event := range batch {
+					if err = replay(ctx, handlers, event); err != nil {
+						return err
+					}
+					versions[aggregateName][event.AggregateID()] = event.AggregateVersion()
+				}
+
+				if len(batch) < batchSize {
+					break
+				}
+				position = postgres.PositionOf(batch[len(batch)-1])
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return replayed, err
+	}
+
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		if err := exec(ctx, tx, fmt.Sprintf("LOCK TABLE %s IN EXCLUSIVE MODE", projection.TableName)); err != nil {
+			return errors.Wrapf(err, "locking projection %s", name)
+		}
+
+		handlers := projection.NewHandlers(shadowTable, tx)
+		events := postgres.NewEventReader(r.eventsTable, tx, r.registry)
+
+		// catch up with the events saved while the shadow was filled
+		for _, aggregateName := range projection.AggregateNames {
+			current, err := events.StreamVersions(ctx, aggregateName)
+			if err != nil {
+				return err
+			}
+
+			for _, id := range behind(current, versions[aggregateName]) {
+				position := postgres.EventPosition{AggregateID: id, Version: versions[aggregateName][id]}
+				for {
+					batch, err := events.ReadEvents(ctx, aggregateName, position, batchSize, id)
+					if err != nil {
+						return err
+					}
+
+					for _, event := range batch {
+						if err = replay(ctx, handlers, event); err != nil {
+							return err
+						}
+					}
+
+					if len(batch) < batchSize {
+						break
+					}
+					position = postgres.PositionOf(batch[len(batch)-1])
+				}
+			}
+		}
+
+		statements := []string{
+			fmt.Sprintf("DELETE FROM %s", projection.TableName),
+			fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", projection.TableName, shadowTable),
+			fmt.Sprintf("DROP TABLE %s", shadowTable),
+		}
+		if err := exec(ctx, tx, statements...); err != nil {
+			return errors.Wrapf(err, "refilling projection %s", name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		if _, dropErr := r.db.ExecContext(context.Background(), fmt.Sprintf("DROP TABLE IF EXISTS %s", shadowTable)); dropErr != nil {
+			r.logger.Error().Err(dropErr).Msgf("dropping shadow table %s", shadowTable)
+		}
+		return replayed, err
+	}
+
+	r.logger.Info().Msgf("rebuilt projection %s: %d events replayed", name, replayed)
+
+	return replayed, nil
+}
+
+func (r Rebuilder) inTx(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	var tx *sql.Tx
+	tx, err = r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	return fn(tx)
+}
+
+// behind returns, in order, the ids of the streams that are past the
+// replayed versions
+func behind(current, replayed map[string]int) []string {
+	var ids []string
+	for id, version := range current {
+		if version > replayed[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func exec(ctx context.Context, tx *sql.Tx, statements ...string) error {
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func splitTableName(tableName string) (schema, table string) {
+	if i := strings.LastIndex(tableName, "."); i != -1 {
+		return tableName[:i], tableName[i+1:]
+	}
+
+	return "", tableName
+}
+
+func qualify(schema, table string) string {
+	if schema == "" {
+		return table
+	}
+
+	return fmt.Sprintf("%s.%s", schema, table)
+}
@@ -0,0 +1,168 @@
+package projections_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/rs/zerolog"
+
+	"github.com/v8tix/eda/ddd"
+	"github.com/v8tix/eda/registry"
+	"github.com/v8tix/eda/registry/serdes"
+	"github.com/v8tix/mallbots-stores/internal/projections"
+)
+
+// testDatabaseEnv names the database the rebuild is tested against; the test
+// is skipped when it is not set
+const testDatabaseEnv = "STORES_TEST_PG_URI"
+
+const rebuildSchema = `
+DROP SCHEMA IF EXISTS rebuild_test CASCADE;
+CREATE SCHEMA rebuild_test;
+
+CREATE TABLE rebuild_test.events
+(
+  stream_id      text        NOT NULL,
+  stream_name    text        NOT NULL,
+  stream_version int         NOT NULL,
+  event_id       text        NOT NULL,
+  event_name     text        NOT NULL,
+  event_data     bytea       NOT NULL,
+  metadata       bytea       NOT NULL DEFAULT '{}'::bytea,
+  occurred_at    timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (stream_id, stream_name, stream_version)
+);
+
+CREATE TABLE rebuild_test.counts
+(
+  id    text NOT NULL PRIMARY KEY,
+  total int  NOT NULL
+);
+
+CREATE TABLE rebuild_test.audit
+(
+  id text NOT NULL
+);
+
+CREATE FUNCTION rebuild_test.audit_counts() RETURNS trigger AS $$
+BEGIN
+  INSERT INTO rebuild_test.audit (id) VALUES (NEW.id);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_counts
+  AFTER INSERT OR UPDATE ON rebuild_test.counts
+  FOR EACH ROW EXECUTE FUNCTION rebuild_test.audit_counts();
+
+CREATE VIEW rebuild_test.totals AS SELECT id, total FROM rebuild_test.counts;
+
+GRANT SELECT ON rebuild_test.counts TO PUBLIC;
+
+INSERT INTO rebuild_test.counts (id, total) VALUES ('stale', 10);
+
+INSERT INTO rebuild_test.events (stream_id, stream_name, stream_version, event_id, event_name, event_data)
+VALUES ('a', 'test.Counter', 1, 'event-1', 'test.Counted', '{}'),
+       ('a', 'test.Counter', 2, 'event-2', 'test.Counted', '{}'),
+       ('b', 'test.Counter', 1, 'event-3', 'test.Counted', '{}');
+`
+
+type counted struct{}
+
+func TestRebuilderKeepsLiveTable(t *testing.T) {
+	uri := os.Getenv(testDatabaseEnv)
+	if uri == "" {
+		t.Skipf("%s is not set", testDatabaseEnv)
+	}
+
+	ctx := context.Background()
+	db, err := sql.Open("pgx", uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err = db.ExecContext(ctx, rebuildSchema); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = db.ExecContext(ctx, "DROP SCHEMA rebuild_test CASCADE")
+	}()
+
+	reg := registry.New()
+	if err = serdes.NewJsonSerde(reg).RegisterKey("test.Counted", counted{}); err != nil {
+		t.Fatal(err)
+	}
+
+	rebuilder := projections.NewRebuilder(db, "rebuild_test.events", reg, zerolog.Nop(), projections.Projection{
+		Name:           "counts",
+		TableName:      "rebuild_test.counts",
+		AggregateNames: []string{"test.Counter"},
+		NewHandlers: func(tableName string, tx *sql.Tx) ddd.EventHandler[ddd.AggregateEvent] {
+			return ddd.EventHandlerFunc[ddd.AggregateEvent](func(ctx context.Context, event ddd.AggregateEvent) error {
+				_, err := tx.ExecContext(ctx,
+					"INSERT INTO "+tableName+" AS c (id, total) VALUES ($1, 1) ON CONFLICT (id) DO UPDATE SET total = c.total + 1",
+					event.AggregateID(),
+				)
+				return err
+			})
+		},
+	})
+
+	replayed, err := rebuilder.Rebuild(ctx, "counts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != 3 {
+		t.Errorf("replayed = %d, want 3", replayed)
+	}
+
+	tests := map[string]struct {
+		query string
+		want  int
+	}{
+		"rebuilt rows": {
+			query: "SELECT count(*) FROM rebuild_test.counts WHERE (id, total) IN (('a', 2), ('b', 1))",
+			want:  2,
+		},
+		"stale rows removed": {
+			query: "SELECT count(*) FROM rebuild_test.counts WHERE id = 'stale'",
+			want:  0,
+		},
+		"trigger kept": {
+			query: "SELECT count(*) FROM pg_trigger WHERE tgrelid = 'rebuild_test.counts'::regclass AND tgname = 'audit_counts'",
+			want:  1,
+		},
+		"trigger fired on refill": {
+			query: "SELECT count(*) FROM rebuild_test.audit WHERE id IN ('a', 'b')",
+			want:  2,
+		},
+		"grant kept": {
+			query: "SELECT count(*) FROM information_schema.role_table_grants WHERE table_schema = 'rebuild_test' AND table_name = 'counts' AND grantee = 'PUBLIC'",
+			want:  1,
+		},
+		"view kept": {
+			query: "SELECT count(*) FROM rebuild_test.totals",
+			want:  2,
+		},
+		"shadow dropped": {
+			query: "SELECT count(*) FROM pg_class WHERE oid = to_regclass('rebuild_test.counts_rebuild')",
+			want:  0,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got int
+			if err := db.QueryRowContext(ctx, tc.query).Scan(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
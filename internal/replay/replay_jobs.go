@@ -0,0 +1,129 @@
+package replay
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stackus/errors"
+)
+
+// maxFinishedJobs bounds how many finished replays are remembered
+const maxFinishedJobs = 100
+
+const (
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+)
+
+var (
+	ErrUnknownReplayJob = errors.Wrap(errors.ErrNotFound, "the replay job does not exist")
+	ErrReplayRunning    = errors.Wrap(errors.ErrConflict, "the replay is already running")
+)
+
+type (
+	JobState string
+
+	// ReplayJob is the progress of a replay started by ReplayJobs; Replayed
+	// includes the events covered by earlier runs of the replay
+	ReplayJob struct {
+		ID         string     `json:"id"`
+		Name       string     `json:"name"`
+		State      JobState   `json:"state"`
+		Replayed   int        `json:"replayed"`
+		Error      string     `json:"error,omitempty"`
+		StartedAt  time.Time  `json:"started_at"`
+		FinishedAt *time.Time `json:"finished_at,omitempty"`
+	}
+
+	// ReplayJobs runs replays in the background so that their progress can
+	// be polled; a replay name is run by one job at a time. Replays are
+	// stopped when ctx is done and resume from their checkpoints when they
+	// are started again; the jobs are only kept in memory.
+	ReplayJobs struct {
+		ctx      context.Context
+		replayer Replayer
+
+		mu       sync.RWMutex
+		jobs     map[string]*ReplayJob
+		finished []string
+	}
+)
+
+func NewReplayJobs(ctx context.Context, replayer Replayer) *ReplayJobs {
+	return &ReplayJobs{
+		ctx:      ctx,
+		replayer: replayer,
+		jobs:     make(map[string]*ReplayJob),
+	}
+}
+
+// Start starts the replay and returns the new job
+func (j *ReplayJobs) Start(request Request) (ReplayJob, error) {
+	if _, err := j.replayer.aggregatesOf(request); err != nil {
+		return ReplayJob{}, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, job := range j.jobs {
+		if job.Name == request.Name && job.State == JobRunning {
+			return ReplayJob{}, ErrReplayRunning
+		}
+	}
+
+	job := &ReplayJob{
+		ID:        uuid.New().String(),
+		Name:      request.Name,
+		State:     JobRunning,
+		StartedAt: time.Now(),
+	}
+	j.jobs[job.ID] = job
+
+	go j.run(job.ID, request)
+
+	return *job, nil
+}
+
+// Get returns the progress of the job
+func (j *ReplayJobs) Get(id string) (ReplayJob, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	job, exists := j.jobs[id]
+	if !exists {
+		return ReplayJob{}, ErrUnknownReplayJob
+	}
+
+	return *job, nil
+}
+
+func (j *ReplayJobs) run(id string, request Request) {
+	replayed, err := j.replayer.replay(j.ctx, request, func(replayed int) {
+		j.mu.Lock()
+		j.jobs[id].Replayed = replayed
+		j.mu.Unlock()
+	})
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job := j.jobs[id]
+	job.Replayed = replayed
+	job.State = JobSucceeded
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	if err != nil {
+		job.State = JobFailed
+		job.Error = err.Error()
+	}
+
+	j.finished = append(j.finished, id)
+	if len(j.finished) > maxFinishedJobs {
+		delete(j.jobs, j.finished[0])
+		j.finished = j.finished[1:]
+	}
+}
@@ -0,0 +1,245 @@
+package replay
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/rs/zerolog"
+	"github.com/stackus/errors"
+	"golang.org/x/time/rate"
+
+	"github.com/v8tix/eda/am"
+	"github.com/v8tix/eda/ddd"
+	"github.com/v8tix/eda/registry"
+	"github.com/v8tix/mallbots-stores/internal/handlers"
+	"github.com/v8tix/mallbots-stores/internal/outbox"
+	"github.com/v8tix/mallbots-stores/internal/postgres"
+)
+
+const (
+	batchSize = 100
+
+	// ReplayMetadataKey marks replayed integration events with the name of
+	// the replay; they are also marked with outbox.ReplayedMetadataKey
+	ReplayMetadataKey = "replay"
+)
+
+var (
+	ErrUnknownAggregate = errors.Wrap(errors.ErrNotFound, "the aggregate does not exist")
+	ErrReplayNameBlank  = errors.Wrap(errors.ErrBadRequest, "the replay name cannot be blank")
+)
+
+type (
+	// PublisherFactory builds the publisher replayed events are written to
+	// within the transaction of a batch
+	PublisherFactory func(tx *sql.Tx) am.MessagePublisher[ddd.Event]
+
+	// Request selects what to replay. The progress of a replay is kept under
+	// its Name; repeating a request resumes it unless Restart is set.
+	Request struct {
+		Name           string
+		AggregateNames []string
+		AggregateIDs   []string
+		// Subject replaces the aggregate channels when set
+		Subject string
+		// Rate limits the events replayed per second; zero is unlimited
+		Rate    int
+		Restart bool
+	}
+
+	// Replayer republishes the integration events of stored domain events,
+	// translated by the same handlers as live events, through the outbox
+	Replayer struct {
+		db               *sql.DB
+		eventsTable      string
+		checkpointsTable string
+		registry         registry.Registry
+		newPublisher     PublisherFactory
+		aggregateNames   []string
+		logger           zerolog.Logger
+	}
+
+	replayPublisher struct {
+		am.MessagePublisher[ddd.Event]
+		name    string
+		subject string
+	}
+)
+
+func NewReplayer(db *sql.DB, eventsTable, checkpointsTable string, registry registry.Registry, newPublisher PublisherFactory, logger zerolog.Logger, aggregateNames ...string) Replayer {
+	return Replayer{
+		db:               db,
+		eventsTable:      eventsTable,
+		checkpointsTable: checkpointsTable,
+		registry:         registry,
+		newPublisher:     newPublisher,
+		aggregateNames:   aggregateNames,
+		logger:           logger,
+	}
+}
+
+// Replay replays the requested events and returns how many events the
+// replay has covered in total, including those of earlier runs
+func (r Replayer) Replay(ctx context.Context, request Request) (replayed int, err error) {
+	return r.replay(ctx, request, func(int) {})
+}
+
+// replay reports the number of events covered so far to progress after
+// every batch
+func (r Replayer) replay(ctx context.Context, request Request, progress func(replayed int)) (replayed int, err error) {
+	aggregateNames, err := r.aggregatesOf(request)
+	if err != nil {
+		return 0, err
+	}
+
+	if request.Restart {
+		if err = postgres.NewReplayCheckpointStore(r.checkpointsTable, r.db).Delete(ctx, request.Name); err != nil {
+			return 0, err
+		}
+	}
+
+	limit := batchSize
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if request.Rate > 0 {
+		// keep each batch to about a second
+		if request.Rate < limit {
+			limit = request.Rate
+		}
+		limiter = rate.NewLimiter(rate.Limit(request.Rate), limit)
+	}
+
+	for _, aggregateName := range aggregateNames {
+		var count int
+		count, err = r.replayAggregate(ctx, request, aggregateName, limiter, limit, func(count int) {
+			progress(replayed + count)
+		})
+		replayed += count
+		if err != nil {
+			return replayed, err
+		}
+	}
+
+	return replayed, nil
+}
+
+// aggregatesOf checks the request and returns the aggregates it replays
+func (r Replayer) aggregatesOf(request Request) ([]string, error) {
+	if request.Name == "" {
+		return nil, ErrReplayNameBlank
+	}
+
+	if len(request.AggregateNames) == 0 {
+		return r.aggregateNames, nil
+	}
+	for _, aggregateName := range request.AggregateNames {
+		if !r.knows(aggregateName) {
+			return nil, ErrUnknownAggregate
+		}
+	}
+
+	return request.AggregateNames, nil
+}
+
+func (r Replayer) replayAggregate(ctx context.Context, request Request, aggregateName string, limiter *rate.Limiter, limit int, progress func(replayed int)) (replayed int, err error) {
+	r.logger.Info().Msgf("replaying %s events for replay %s", aggregateName, request.Name)
+
+	for {
+		var done bool
+		replayed, done, err = r.replayBatch(ctx, request, aggregateName, limiter, limit)
+		if err != nil || done {
+			break
+		}
+		progress(replayed)
+	}
+
+	if err != nil {
+		r.logger.Error().Err(err).Msgf("replay %s stopped after %d %s events; it resumes from there", request.Name, replayed, aggregateName)
+		return replayed, err
+	}
+
+	r.logger.Info().Msgf("replay %s replayed %d %s events", request.Name, replayed, aggregateName)
+
+	return replayed, nil
+}
+
+// replayBatch replays the batch that follows the checkpoint and moves the
+// checkpoint past it; the outbox rows and the checkpoint are committed
+// together. The rate limit is waited out for the whole batch before its
+// transaction begins so that no transaction is held open while waiting.
+func (r Replayer) replayBatch(ctx context.Context, request Request, aggregateName string, limiter *rate.Limiter, limit int) (replayed int, done bool, err error) {
+	if err = limiter.WaitN(ctx, limit); err != nil {
+		return 0, false, err
+	}
+
+	var tx *sql.Tx
+	tx, err = r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	checkpoints := postgres.NewReplayCheckpointStore(r.checkpointsTable, tx)
+
+	var position postgres.EventPosition
+	var checkpointed int
+	position, checkpointed, err = checkpoints.Find(ctx, request.Name, aggregateName)
+	if err != nil {
+		return 0, false, err
+	}
+	replayed = checkpointed
+
+	var batch []ddd.AggregateEvent
+	batch, err = postgres.NewEventReader(r.eventsTable, tx, r.registry).ReadEvents(ctx, aggregateName, position, limit, request.AggregateIDs...)
+	if err != nil {
+		return replayed, false, err
+	}
+
+	domainHandlers := handlers.NewDomainEventHandlers(replayPublisher{
+		MessagePublisher: r.newPublisher(tx),
+		name:             request.Name,
+		subject:          request.Subject,
+	})
+
+	for _, event := range batch {
+		if err = domainHandlers.HandleEvent(ctx, event); err != nil {
+			return checkpointed, false, errors.Wrapf(err, "replaying %s event %s", event.EventName(), event.ID())
+		}
+		replayed++
+	}
+
+	if len(batch) != 0 {
+		position = postgres.PositionOf(batch[len(batch)-1])
+		if err = checkpoints.Save(ctx, request.Name, aggregateName, position, replayed); err != nil {
+			return checkpointed, false, err
+		}
+	}
+
+	return replayed, len(batch) < limit, nil
+}
+
+func (r Replayer) knows(aggregateName string) bool {
+	for _, name := range r.aggregateNames {
+		if name == aggregateName {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p replayPublisher) Publish(ctx context.Context, topicName string, event ddd.Event) error {
+	event.Metadata().Set(ReplayMetadataKey, p.name)
+	event.Metadata().Set(outbox.ReplayedMetadataKey, true)
+
+	if p.subject != "" {
+		topicName = p.subject
+	}
+
+	return p.MessagePublisher.Publish(ctx, topicName, event)
+}
@@ -0,0 +1,120 @@
+package rpc
+
+import (
+	"context"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stackus/errors"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// defaultTimeout bounds requests when the server is not given a timeout
+const defaultTimeout = 30 * time.Second
+
+// Interceptors holds the unary interceptors that modules add for their own
+// services. The server runs them, in the order they were added, after the
+// interceptors that every service shares.
+type Interceptors struct {
+	mu       sync.RWMutex
+	services map[string][]grpc.UnaryServerInterceptor
+}
+
+func NewInterceptors() *Interceptors {
+	return &Interceptors{
+		services: make(map[string][]grpc.UnaryServerInterceptor),
+	}
+}
+
+// Add adds interceptors for the named service, e.g. "pb.StoresService"
+func (i *Interceptors) Add(serviceName string, interceptors ...grpc.UnaryServerInterceptor) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.services[serviceName] = append(i.services[serviceName], interceptors...)
+}
+
+// Unary runs the interceptors added for the service of the called method
+func (i *Interceptors) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		i.mu.RLock()
+		interceptors := i.services[serviceName(info.FullMethod)]
+		i.mu.RUnlock()
+
+		for j := len(interceptors) - 1; j >= 0; j-- {
+			interceptor, next := interceptors[j], handler
+			handler = func(ctx context.Context, req any) (any, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// Recovery turns panics into internal errors so that a single request cannot
+// take the server down
+func Recovery(logger zerolog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				logger.Error().Msgf("panic in %s: %v\n%s", info.FullMethod, p, debug.Stack())
+				err = errors.SendGRPCError(errors.ErrInternal.Msg("internal server error"))
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// Logging logs every request along with its status code and duration; the
+// health probes are not logged as they arrive every few seconds
+func Logging(logger zerolog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		if serviceName(info.FullMethod) == healthpb.Health_ServiceDesc.ServiceName {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		logger.Info().Msgf("--> RPC %s", info.FullMethod)
+		defer func() {
+			logger.Info().Err(err).
+				Str("code", status.Code(err).String()).
+				Dur("duration", time.Since(start)).
+				Msgf("<-- RPC %s", info.FullMethod)
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// Deadline gives every request a deadline no later than timeout from now;
+// callers may ask for an earlier one
+func Deadline(timeout time.Duration) grpc.UnaryServerInterceptor {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return handler(ctx, req)
+	}
+}
+
+// serviceName returns the service of a full method name, e.g.
+// "pb.StoresService" for "/pb.StoresService/CreateStore"
+func serviceName(fullMethod string) string {
+	name := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i]
+	}
+
+	return name
+}
@@ -0,0 +1,5 @@
+// Package statepb holds the full state integration events of the stores
+// module until the shared stores protos carry them
+package statepb
+
+//go:generate protoc --proto_path=../.. --go_out=../.. --go_opt=paths=source_relative internal/statepb/state.proto
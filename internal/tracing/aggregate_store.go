@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/v8tix/eda/es"
+)
+
+type aggregateStore struct {
+	es.AggregateStore
+}
+
+var _ es.AggregateStore = (*aggregateStore)(nil)
+
+// NewAggregateStoreMiddleware traces the loading and saving of aggregates;
+// as the first middleware its spans include publishing the saved events to
+// the projections and the outbox
+func NewAggregateStoreMiddleware() es.AggregateStoreMiddleware {
+	return func(store es.AggregateStore) es.AggregateStore {
+		return aggregateStore{AggregateStore: store}
+	}
+}
+
+func (s aggregateStore) Load(ctx context.Context, aggregate es.EventSourcedAggregate) (err error) {
+	ctx, span := Start(ctx, "AggregateStore.Load", trace.WithAttributes(aggregateAttributes(aggregate)...))
+	defer func() { End(span, err) }()
+	return s.AggregateStore.Load(ctx, aggregate)
+}
+
+func (s aggregateStore) Save(ctx context.Context, aggregate es.EventSourcedAggregate) (err error) {
+	ctx, span := Start(ctx, "AggregateStore.Save", trace.WithAttributes(aggregateAttributes(aggregate)...))
+	span.SetAttributes(attribute.Int("aggregate.events", len(aggregate.Events())))
+	defer func() { End(span, err) }()
+	return s.AggregateStore.Save(ctx, aggregate)
+}
+
+func aggregateAttributes(aggregate es.EventSourcedAggregate) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("aggregate.name", aggregate.AggregateName()),
+		attribute.String("aggregate.id", aggregate.ID()),
+	}
+}
@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/v8tix/eda/ddd"
+)
+
+type (
+	// MetadataCarrier carries the trace context in event metadata, which is
+	// how it travels through the outbox
+	MetadataCarrier ddd.Metadata
+
+	// FieldsCarrier reads the trace context from the metadata of a serialized
+	// event message
+	FieldsCarrier map[string]*structpb.Value
+
+	// HeaderCarrier carries the trace context in NATS headers; unlike
+	// propagation.HeaderCarrier it keeps the keys as they are given
+	HeaderCarrier nats.Header
+)
+
+var _ propagation.TextMapCarrier = (*MetadataCarrier)(nil)
+var _ propagation.TextMapCarrier = (*FieldsCarrier)(nil)
+var _ propagation.TextMapCarrier = (*HeaderCarrier)(nil)
+
+func (c MetadataCarrier) Get(key string) string {
+	value, _ := c[key].(string)
+	return value
+}
+
+func (c MetadataCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c MetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (c FieldsCarrier) Get(key string) string {
+	return c[key].GetStringValue()
+}
+
+// Set is a no-op; serialized messages are not changed
+func (c FieldsCarrier) Set(string, string) {}
+
+func (c FieldsCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (c HeaderCarrier) Get(key string) string {
+	if values := c[key]; len(values) != 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	c[key] = []string{value}
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
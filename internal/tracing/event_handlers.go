@@ -0,0 +1,33 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/v8tix/eda/ddd"
+)
+
+type EventHandlers[T ddd.Event] struct {
+	ddd.EventHandler[T]
+	label string
+}
+
+var _ ddd.EventHandler[ddd.Event] = (*EventHandlers[ddd.Event])(nil)
+
+func TraceEventHandlers[T ddd.Event](handlers ddd.EventHandler[T], label string) EventHandlers[T] {
+	return EventHandlers[T]{
+		EventHandler: handlers,
+		label:        label,
+	}
+}
+
+func (h EventHandlers[T]) HandleEvent(ctx context.Context, event T) (err error) {
+	ctx, span := Start(ctx, "Stores."+h.label+".On("+event.EventName()+")", trace.WithAttributes(
+		attribute.String("event.id", event.ID()),
+		attribute.String("event.name", event.EventName()),
+	))
+	defer func() { End(span, err) }()
+	return h.EventHandler.HandleEvent(ctx, event)
+}
@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/v8tix/eda/am"
+	"github.com/v8tix/eda/ddd"
+)
+
+type eventStream struct {
+	am.EventStream
+}
+
+var _ am.EventStream = (*eventStream)(nil)
+
+// TraceEventStream traces publishing integration events and puts the trace
+// context into their metadata, so that it survives the outbox and is
+// continued when the messages are published to NATS
+func TraceEventStream(stream am.EventStream) am.EventStream {
+	return eventStream{EventStream: stream}
+}
+
+func (s eventStream) Publish(ctx context.Context, topicName string, event ddd.Event) (err error) {
+	ctx, span := Start(ctx, "EventStream.Publish("+event.EventName()+")", trace.WithAttributes(
+		attribute.String("event.id", event.ID()),
+		attribute.String("event.name", event.EventName()),
+		attribute.String("messaging.destination", topicName),
+	))
+	defer func() { End(span, err) }()
+
+	otel.GetTextMapPropagator().Inject(ctx, MetadataCarrier(event.Metadata()))
+
+	return s.EventStream.Publish(ctx, topicName, event)
+}
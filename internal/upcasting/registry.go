@@ -0,0 +1,141 @@
+package upcasting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/v8tix/eda/registry"
+)
+
+// versionField is added to serialized payloads once their key has upcasters;
+// payloads without it are version 1
+const versionField = "$v"
+
+type (
+	// Upcaster transforms a serialized payload from one schema version into
+	// the next
+	Upcaster func(data []byte) ([]byte, error)
+
+	// Registry upcasts historic payloads to the current schema version of
+	// their key before they are deserialized. Every payload serialized for a
+	// key with upcasters is stamped with the current schema version.
+	Registry struct {
+		registry.Registry
+		upcasters map[string][]Upcaster
+		mu        sync.RWMutex
+	}
+)
+
+var _ registry.Registry = (*Registry)(nil)
+
+func NewRegistry(reg registry.Registry) *Registry {
+	return &Registry{
+		Registry:  reg,
+		upcasters: make(map[string][]Upcaster),
+	}
+}
+
+// Register adds the upcaster of key from version to version+1; the
+// upcasters of a key are registered in order starting from version 1
+func (r *Registry) Register(key string, version int, upcaster Upcaster) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if expected := len(r.upcasters[key]) + 1; version != expected {
+		return fmt.Errorf("the next upcaster for %s must be from version %d, got %d", key, expected, version)
+	}
+
+	r.upcasters[key] = append(r.upcasters[key], upcaster)
+
+	return nil
+}
+
+func (r *Registry) Serialize(key string, v interface{}) ([]byte, error) {
+	data, err := r.Registry.Serialize(key, v)
+	if err != nil {
+		return nil, err
+	}
+
+	version := r.currentVersion(key)
+	if version == 1 {
+		return data, nil
+	}
+
+	return stampVersion(key, data, version)
+}
+
+func (r *Registry) Deserialize(key string, data []byte, options ...registry.BuildOption) (interface{}, error) {
+	r.mu.RLock()
+	upcasters := r.upcasters[key]
+	r.mu.RUnlock()
+
+	if len(upcasters) != 0 {
+		version, err := schemaVersion(data)
+		if err != nil {
+			return nil, fmt.Errorf("reading the schema version of %s: %w", key, err)
+		}
+
+		for ; version <= len(upcasters); version++ {
+			data, err = upcasters[version-1](data)
+			if err != nil {
+				return nil, fmt.Errorf("upcasting %s from version %d: %w", key, version, err)
+			}
+		}
+	}
+
+	return r.Registry.Deserialize(key, data, options...)
+}
+
+func (r *Registry) currentVersion(key string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.upcasters[key]) + 1
+}
+
+// JSONUpcaster builds an Upcaster for JSON payloads from a function that
+// edits the decoded payload in place
+func JSONUpcaster(fn func(payload map[string]any) error) Upcaster {
+	return func(data []byte) ([]byte, error) {
+		payload := map[string]any{}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return nil, err
+		}
+		if err := fn(payload); err != nil {
+			return nil, err
+		}
+		return json.Marshal(payload)
+	}
+}
+
+func schemaVersion(data []byte) (int, error) {
+	var stamp map[string]json.RawMessage
+	if err := json.Unmarshal(data, &stamp); err != nil {
+		return 0, err
+	}
+
+	raw, exists := stamp[versionField]
+	if !exists {
+		return 1, nil
+	}
+
+	return strconv.Atoi(string(raw))
+}
+
+func stampVersion(key string, data []byte, version int) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) < 2 || data[0] != '{' {
+		return nil, fmt.Errorf("%s payloads must be JSON objects to be versioned", key)
+	}
+
+	stamped := bytes.NewBufferString(fmt.Sprintf(`{%q:%d`, versionField, version))
+	if body := bytes.TrimSpace(data[1:]); body[0] != '}' {
+		stamped.WriteByte(',')
+	}
+	stamped.Write(data[1:])
+
+	return stamped.Bytes(), nil
+}
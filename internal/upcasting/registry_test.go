@@ -0,0 +1,114 @@
+package upcasting
+
+import (
+	"testing"
+
+	"github.com/v8tix/eda/registry"
+	"github.com/v8tix/eda/registry/serdes"
+)
+
+type renamed struct {
+	Name  string
+	Title string
+}
+
+const renamedKey = "test.Renamed"
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+
+	reg := NewRegistry(registry.New())
+	if err := serdes.NewJsonSerde(reg).RegisterKey(renamedKey, renamed{}); err != nil {
+		t.Fatal(err)
+	}
+
+	return reg
+}
+
+func TestRegistryRegister(t *testing.T) {
+	reg := newTestRegistry(t)
+
+	upcaster := JSONUpcaster(func(map[string]any) error { return nil })
+	if err := reg.Register(renamedKey, 2, upcaster); err == nil {
+		t.Error("Register() from version 2 before version 1 succeeded, want an error")
+	}
+	if err := reg.Register(renamedKey, 1, upcaster); err != nil {
+		t.Errorf("Register() error = %v", err)
+	}
+	if err := reg.Register(renamedKey, 1, upcaster); err == nil {
+		t.Error("Register() from version 1 twice succeeded, want an error")
+	}
+}
+
+func TestRegistryDeserialize(t *testing.T) {
+	reg := newTestRegistry(t)
+	// version 1 named the field "Label"; version 2 renamed it "Name" and
+	// version 3 added the "Title"
+	if err := reg.Register(renamedKey, 1, JSONUpcaster(func(payload map[string]any) error {
+		payload["Name"] = payload["Label"]
+		delete(payload, "Label")
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Register(renamedKey, 2, JSONUpcaster(func(payload map[string]any) error {
+		payload["Title"] = "untitled"
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		data string
+		want renamed
+	}{
+		"unversioned payloads are version 1": {
+			data: `{"Label":"widget"}`,
+			want: renamed{Name: "widget", Title: "untitled"},
+		},
+		"version 2": {
+			data: `{"$v":2,"Name":"widget"}`,
+			want: renamed{Name: "widget", Title: "untitled"},
+		},
+		"current version": {
+			data: `{"$v":3,"Name":"widget","Title":"gadget"}`,
+			want: renamed{Name: "widget", Title: "gadget"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			v, err := reg.Deserialize(renamedKey, []byte(tc.data))
+			if err != nil {
+				t.Fatalf("Deserialize() error = %v", err)
+			}
+			if got := *v.(*renamed); got != tc.want {
+				t.Errorf("Deserialize() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRegistrySerialize(t *testing.T) {
+	reg := newTestRegistry(t)
+
+	data, err := reg.Serialize(renamedKey, renamed{Name: "widget"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Name":"widget","Title":""}`; string(data) != want {
+		t.Errorf("Serialize() = %s, want %s", data, want)
+	}
+
+	if err = reg.Register(renamedKey, 1, JSONUpcaster(func(map[string]any) error { return nil })); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = reg.Serialize(renamedKey, renamed{Name: "widget"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"$v":2,"Name":"widget","Title":""}`; string(data) != want {
+		t.Errorf("Serialize() = %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,30 @@
+package upcasting
+
+import (
+	"github.com/v8tix/mallbots-stores/internal/domain"
+)
+
+// Upcasters registers the transformations of historic stores event payloads
+// into their current shape. When an event gains or changes fields, register
+// an upcaster from its previous schema version here and add a fixture of the
+// previous version to testdata.
+func Upcasters(reg *Registry) error {
+	for _, key := range []string{domain.ProductPriceIncreasedEvent, domain.ProductPriceDecreasedEvent} {
+		if err := reg.Register(key, 1, JSONUpcaster(productPriceChangedV1)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// productPriceChangedV1 marks the price changes recorded before the absolute
+// prices were; they carry only the Delta, and the product fills in the
+// prices when the change is applied
+func productPriceChangedV1(payload map[string]any) error {
+	if _, exists := payload["Price"]; !exists {
+		payload["DeltaOnly"] = true
+	}
+
+	return nil
+}
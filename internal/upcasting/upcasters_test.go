@@ -0,0 +1,136 @@
+package upcasting_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/v8tix/eda/ddd"
+	"github.com/v8tix/eda/registry"
+	"github.com/v8tix/eda/registry/serdes"
+	"github.com/v8tix/mallbots-stores/internal/domain"
+	"github.com/v8tix/mallbots-stores/internal/upcasting"
+)
+
+func newRegistry(t *testing.T) *upcasting.Registry {
+	t.Helper()
+
+	reg := upcasting.NewRegistry(registry.New())
+	serde := serdes.NewJsonSerde(reg)
+	if err := serde.Register(domain.ProductAdded{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := serde.RegisterKey(domain.ProductPriceIncreasedEvent, domain.ProductPriceChanged{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := serde.RegisterKey(domain.ProductPriceDecreasedEvent, domain.ProductPriceChanged{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := upcasting.Upcasters(reg); err != nil {
+		t.Fatal(err)
+	}
+
+	return reg
+}
+
+func TestUpcastersProductPriceChanged(t *testing.T) {
+	tests := map[string]struct {
+		fixture       string
+		key           string
+		wantDelta     float64
+		wantPrice     float64
+		wantPrevious  float64
+		wantDeltaOnly bool
+	}{
+		"increase recorded with only the delta": {
+			fixture:       "product_price_increased_v1.json",
+			key:           domain.ProductPriceIncreasedEvent,
+			wantDelta:     2.5,
+			wantPrice:     12.5,
+			wantPrevious:  10,
+			wantDeltaOnly: true,
+		},
+		"decrease recorded with only the delta": {
+			fixture:       "product_price_decreased_v1.json",
+			key:           domain.ProductPriceDecreasedEvent,
+			wantDelta:     -1.25,
+			wantPrice:     8.75,
+			wantPrevious:  10,
+			wantDeltaOnly: true,
+		},
+		"increase recorded with prices before it was versioned": {
+			fixture:      "product_price_increased_v1_with_prices.json",
+			key:          domain.ProductPriceIncreasedEvent,
+			wantDelta:    2.5,
+			wantPrice:    12.5,
+			wantPrevious: 10,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			reg := newRegistry(t)
+
+			data, err := os.ReadFile(filepath.Join("testdata", tc.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			v, err := reg.Deserialize(tc.key, data)
+			if err != nil {
+				t.Fatalf("Deserialize() error = %v", err)
+			}
+			payload, ok := v.(*domain.ProductPriceChanged)
+			if !ok {
+				t.Fatalf("Deserialize() = %T, want *domain.ProductPriceChanged", v)
+			}
+			if payload.Delta != tc.wantDelta || payload.DeltaOnly != tc.wantDeltaOnly {
+				t.Errorf("Deserialize() = %+v, want Delta %v and DeltaOnly %v", payload, tc.wantDelta, tc.wantDeltaOnly)
+			}
+
+			product := domain.NewProduct("product-id")
+			if err = product.ApplyEvent(ddd.NewEvent(domain.ProductAddedEvent, &domain.ProductAdded{
+				StoreID: "store-id",
+				Name:    "Widget",
+				Price:   10,
+			})); err != nil {
+				t.Fatal(err)
+			}
+			if err = product.ApplyEvent(ddd.NewEvent(tc.key, payload)); err != nil {
+				t.Fatalf("ApplyEvent() error = %v", err)
+			}
+
+			if product.Price != tc.wantPrice {
+				t.Errorf("product.Price = %v, want %v", product.Price, tc.wantPrice)
+			}
+			if payload.Price != tc.wantPrice || payload.PreviousPrice != tc.wantPrevious || !payload.HasPrices() {
+				t.Errorf("applied payload = %+v, want Price %v and PreviousPrice %v", payload, tc.wantPrice, tc.wantPrevious)
+			}
+		})
+	}
+}
+
+func TestUpcastersProductPriceChangedCurrentVersion(t *testing.T) {
+	reg := newRegistry(t)
+
+	data, err := reg.Serialize(domain.ProductPriceIncreasedEvent, &domain.ProductPriceChanged{
+		Delta:         2.5,
+		Price:         12.5,
+		PreviousPrice: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte(`{"$v":2,`)) {
+		t.Errorf("Serialize() = %s, want it stamped with version 2", data)
+	}
+
+	v, err := reg.Deserialize(domain.ProductPriceIncreasedEvent, data)
+	if err != nil {
+		t.Fatalf("Deserialize() error = %v", err)
+	}
+	if payload := v.(*domain.ProductPriceChanged); payload.DeltaOnly || payload.Price != 12.5 || payload.PreviousPrice != 10 {
+		t.Errorf("Deserialize() = %+v, want the serialized prices", payload)
+	}
+}
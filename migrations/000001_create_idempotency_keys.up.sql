@@ -0,0 +1,11 @@
+CREATE TABLE stores.idempotency_keys
+(
+  key          text        NOT NULL,
+  method       text        NOT NULL,
+  request_hash bytea       NOT NULL,
+  response     bytea       NOT NULL,
+  created_at   timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (key, method)
+);
+
+CREATE INDEX idempotency_keys_created_at_idx ON stores.idempotency_keys (created_at);
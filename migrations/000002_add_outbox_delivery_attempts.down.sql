@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS stores.outbox_dead_lettered_at_idx;
+
+ALTER TABLE stores.outbox
+  DROP COLUMN IF EXISTS attempts,
+  DROP COLUMN IF EXISTS last_error,
+  DROP COLUMN IF EXISTS next_attempt_at,
+  DROP COLUMN IF EXISTS dead_lettered_at;
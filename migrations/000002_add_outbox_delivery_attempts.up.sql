@@ -0,0 +1,7 @@
+ALTER TABLE stores.outbox
+  ADD COLUMN attempts         int         NOT NULL DEFAULT 0,
+  ADD COLUMN last_error       text,
+  ADD COLUMN next_attempt_at  timestamptz,
+  ADD COLUMN dead_lettered_at timestamptz;
+
+CREATE INDEX outbox_dead_lettered_at_idx ON stores.outbox (dead_lettered_at) WHERE dead_lettered_at IS NOT NULL;
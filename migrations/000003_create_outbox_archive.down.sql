@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS stores.outbox_published_at_idx;
+
+DROP TABLE IF EXISTS stores.outbox_archive;
@@ -0,0 +1,11 @@
+CREATE TABLE stores.outbox_archive
+(
+  id           text        NOT NULL PRIMARY KEY,
+  name         text        NOT NULL,
+  subject      text        NOT NULL,
+  data         bytea       NOT NULL,
+  published_at timestamptz NOT NULL,
+  archived_at  timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX outbox_published_at_idx ON stores.outbox (published_at) WHERE published_at IS NOT NULL;
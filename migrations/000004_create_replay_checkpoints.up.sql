@@ -0,0 +1,10 @@
+CREATE TABLE stores.replay_checkpoints
+(
+  name           text        NOT NULL,
+  aggregate_name text        NOT NULL,
+  stream_id      text        NOT NULL,
+  stream_version int         NOT NULL,
+  replayed       int         NOT NULL DEFAULT 0,
+  updated_at     timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (name, aggregate_name)
+);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS stores.outbox_unpublished_partition_idx;
+DROP INDEX IF EXISTS stores.outbox_unpublished_seq_idx;
+
+ALTER TABLE stores.outbox
+  DROP COLUMN IF EXISTS seq,
+  DROP COLUMN IF EXISTS partition_key,
+  DROP COLUMN IF EXISTS aggregate_version;
@@ -0,0 +1,7 @@
+ALTER TABLE stores.outbox
+  ADD COLUMN seq               bigserial NOT NULL,
+  ADD COLUMN partition_key     text,
+  ADD COLUMN aggregate_version int;
+
+CREATE INDEX outbox_unpublished_seq_idx ON stores.outbox (seq) WHERE published_at IS NULL;
+CREATE INDEX outbox_unpublished_partition_idx ON stores.outbox (partition_key, seq) WHERE published_at IS NULL;
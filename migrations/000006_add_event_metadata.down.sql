@@ -0,0 +1,2 @@
+ALTER TABLE stores.events
+  DROP COLUMN IF EXISTS metadata;
@@ -0,0 +1,3 @@
+-- the metadata is stored as JSON in a bytea, like event_data
+ALTER TABLE stores.events
+  ADD COLUMN metadata bytea NOT NULL DEFAULT '{}'::bytea;
@@ -0,0 +1,2 @@
+ALTER TABLE stores.idempotency_keys
+  DROP COLUMN IF EXISTS aggregate_version;
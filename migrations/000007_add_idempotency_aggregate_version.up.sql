@@ -0,0 +1,4 @@
+-- the version of the aggregate the recorded response left behind, returned
+-- again with replays; zero for responses recorded before it was kept
+ALTER TABLE stores.idempotency_keys
+  ADD COLUMN aggregate_version int NOT NULL DEFAULT 0;
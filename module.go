@@ -3,8 +3,11 @@ package stores
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"github.com/v8tix/mallbots-stores/internal/ms"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/rs/zerolog"
 
 	"github.com/v8tix/eda/am"
@@ -12,35 +15,54 @@ import (
 	"github.com/v8tix/eda/di"
 	"github.com/v8tix/eda/es"
 	"github.com/v8tix/eda/jetstream"
-	pg "github.com/v8tix/eda/postgres"
 	"github.com/v8tix/eda/registry"
 	"github.com/v8tix/eda/registry/serdes"
 	"github.com/v8tix/eda/tm"
 	"github.com/v8tix/mallbots-stores-proto/pb"
 	"github.com/v8tix/mallbots-stores-proto/rest"
+	"github.com/v8tix/mallbots-stores/internal/admin"
 	"github.com/v8tix/mallbots-stores/internal/application"
+	"github.com/v8tix/mallbots-stores/internal/config"
+	"github.com/v8tix/mallbots-stores/internal/correlation"
 	"github.com/v8tix/mallbots-stores/internal/domain"
 	"github.com/v8tix/mallbots-stores/internal/grpc"
 	"github.com/v8tix/mallbots-stores/internal/handlers"
 	"github.com/v8tix/mallbots-stores/internal/logging"
+	"github.com/v8tix/mallbots-stores/internal/metrics"
+	"github.com/v8tix/mallbots-stores/internal/outbox"
 	"github.com/v8tix/mallbots-stores/internal/postgres"
+	"github.com/v8tix/mallbots-stores/internal/projections"
+	"github.com/v8tix/mallbots-stores/internal/replay"
+	"github.com/v8tix/mallbots-stores/internal/statepb"
+	"github.com/v8tix/mallbots-stores/internal/tracing"
+	"github.com/v8tix/mallbots-stores/internal/upcasting"
 )
 
+// outboxChannel is notified when messages are saved to the outbox
+const outboxChannel = "stores_outbox"
+
 type Module struct {
 }
 
 func (m *Module) Startup(ctx context.Context, mono ms.Microservice) (err error) {
+	// invalid settings fail the startup rather than the first request
+	snapshotStrategies, err := newSnapshotStrategies(mono.Config().Snapshots)
+	if err != nil {
+		return err
+	}
+	retentionCfg, err := newRetentionConfig(mono.Config().Outbox)
+	if err != nil {
+		return err
+	}
+	encoding, err := newEncoding(mono.Config().Integration)
+	if err != nil {
+		return err
+	}
+
 	container := di.New()
 	// setup Driven adapters
 	container.AddSingleton("registry", func(c di.Container) (any, error) {
-		reg := registry.New()
-		if err := registrations(reg); err != nil {
-			return nil, err
-		}
-		if err := pb.Registrations(reg); err != nil {
-			return nil, err
-		}
-		return reg, nil
+		return newRegistry()
 	})
 	container.AddSingleton("logger", func(c di.Container) (any, error) {
 		return mono.Logger(), nil
@@ -54,10 +76,101 @@ func (m *Module) Startup(ctx context.Context, mono ms.Microservice) (err error)
 	container.AddSingleton("db", func(c di.Container) (any, error) {
 		return mono.DB(), nil
 	})
+	container.AddSingleton("snapshotStrategies", func(c di.Container) (any, error) {
+		return snapshotStrategies, nil
+	})
+	container.AddSingleton("snapshotRegenerator", func(c di.Container) (any, error) {
+		return postgres.NewSnapshotRegenerator(
+			"stores.snapshots",
+			"stores.events",
+			c.Get("db").(*sql.DB),
+			c.Get("registry").(registry.Registry),
+			c.Get("logger").(zerolog.Logger),
+			domain.StoreAggregate,
+			domain.ProductAggregate,
+		), nil
+	})
+	container.AddSingleton("replayer", func(c di.Container) (any, error) {
+		reg := c.Get("registry").(registry.Registry)
+		stream := c.Get("stream").(am.RawMessageStream)
+		return replay.NewReplayer(
+			c.Get("db").(*sql.DB),
+			"stores.events",
+			"stores.replay_checkpoints",
+			reg,
+			func(tx *sql.Tx) am.MessagePublisher[ddd.Event] {
+				return tracing.TraceEventStream(am.NewEventStream(reg, am.RawMessageStreamWithMiddleware(
+					stream,
+					tm.NewOutboxStreamMiddleware(postgres.NewOutboxStore("stores.outbox", outboxChannel, tx)),
+				)))
+			},
+			c.Get("logger").(zerolog.Logger),
+			domain.StoreAggregate,
+			domain.ProductAggregate,
+		), nil
+	})
+	container.AddSingleton("replayJobs", func(c di.Container) (any, error) {
+		return replay.NewReplayJobs(mono.Waiter().Context(), c.Get("replayer").(replay.Replayer)), nil
+	})
+	container.AddSingleton("rebuilder", func(c di.Container) (any, error) {
+		return projections.NewRebuilder(
+			c.Get("db").(*sql.DB),
+			"stores.events",
+			c.Get("registry").(registry.Registry),
+			c.Get("logger").(zerolog.Logger),
+			readModels()...,
+		), nil
+	})
+	container.AddSingleton("rebuildJobs", func(c di.Container) (any, error) {
+		return projections.NewRebuildJobs(mono.Waiter().Context(), c.Get("rebuilder").(projections.Rebuilder)), nil
+	})
+	container.AddSingleton("outboxStore", func(c di.Container) (any, error) {
+		return postgres.NewOutboxStore("stores.outbox", outboxChannel, c.Get("db").(*sql.DB)), nil
+	})
 	container.AddSingleton("outboxProcessor", func(c di.Container) (any, error) {
-		return tm.NewOutboxProcessor(
-			c.Get("stream").(am.RawMessageStream),
-			pg.NewOutboxStore("stores.outbox", c.Get("db").(*sql.DB)),
+		outboxCfg := mono.Config().Outbox
+		return outbox.NewProcessor(
+			outbox.NewPublisher(mono.JS(), outbox.NewEncoder(
+				encoding,
+				cloudEventsSource(mono.Config().Integration),
+				c.Get("registry").(registry.Registry),
+			)),
+			c.Get("outboxStore").(postgres.OutboxStore),
+			postgres.NewOutboxListener(mono.Config().PG.Conn, outboxChannel),
+			outbox.Config{
+				BatchSize:       outboxCfg.BatchSize,
+				PollingInterval: outboxCfg.PollingInterval,
+				Retries: outbox.RetryPolicy{
+					MaxAttempts: outboxCfg.MaxAttempts,
+					Backoff:     outboxCfg.Backoff,
+					MaxBackoff:  outboxCfg.MaxBackoff,
+				},
+			},
+			c.Get("logger").(zerolog.Logger),
+		), nil
+	})
+	container.AddSingleton("outboxSupervisor", func(c di.Container) (any, error) {
+		return outbox.NewSupervisor(
+			c.Get("outboxProcessor").(outbox.Processor),
+			c.Get("logger").(zerolog.Logger),
+		), nil
+	})
+	container.AddSingleton("outboxRetention", func(c di.Container) (any, error) {
+		return outbox.NewRetentionJob(
+			c.Get("outboxStore").(postgres.OutboxStore),
+			retentionCfg,
+			c.Get("logger").(zerolog.Logger),
+		), nil
+	})
+	container.AddSingleton("idempotencyRetention", func(c di.Container) (any, error) {
+		return postgres.NewIdempotencyRetentionJob(
+			postgres.NewIdempotencyStore(
+				"stores.idempotency_keys",
+				c.Get("db").(*sql.DB),
+				mono.Config().Idempotency.Retention,
+			),
+			mono.Config().Idempotency.PurgeInterval,
+			c.Get("logger").(zerolog.Logger),
 		), nil
 	})
 	container.AddScoped("tx", func(c di.Container) (any, error) {
@@ -66,22 +179,38 @@ func (m *Module) Startup(ctx context.Context, mono ms.Microservice) (err error)
 	})
 	container.AddScoped("txStream", func(c di.Container) (any, error) {
 		tx := c.Get("tx").(*sql.Tx)
-		outboxStore := pg.NewOutboxStore("stores.outbox", tx)
+		outboxStore := postgres.NewOutboxStore("stores.outbox", outboxChannel, tx)
 		return am.RawMessageStreamWithMiddleware(
 			c.Get("stream").(am.RawMessageStream),
 			tm.NewOutboxStreamMiddleware(outboxStore),
 		), nil
 	})
 	container.AddScoped("eventStream", func(c di.Container) (any, error) {
-		return am.NewEventStream(c.Get("registry").(registry.Registry), c.Get("txStream").(am.RawMessageStream)), nil
+		return tracing.TraceEventStream(
+			am.NewEventStream(c.Get("registry").(registry.Registry), c.Get("txStream").(am.RawMessageStream)),
+		), nil
 	})
 	container.AddScoped("aggregateStore", func(c di.Container) (any, error) {
 		tx := c.Get("tx").(*sql.Tx)
 		reg := c.Get("registry").(registry.Registry)
-		return es.AggregateStoreWithMiddleware(
-			pg.NewEventStore("stores.events", tx, reg),
+		middlewares := []es.AggregateStoreMiddleware{
+			tracing.NewAggregateStoreMiddleware(),
+			correlation.NewAggregateStoreMiddleware(),
 			es.NewEventPublisher(c.Get("domainDispatcher").(*ddd.EventDispatcher[ddd.AggregateEvent])),
-			pg.NewSnapshotStore("stores.snapshots", tx, reg),
+		}
+		if mono.Config().Integration.StateTransfer {
+			middlewares = append(middlewares, handlers.NewStateTransferMiddleware())
+		}
+		middlewares = append(middlewares, postgres.NewSnapshotStore(
+			"stores.snapshots",
+			"stores.events",
+			tx,
+			reg,
+			c.Get("snapshotStrategies").(postgres.SnapshotStrategies),
+		))
+		return es.AggregateStoreWithMiddleware(
+			postgres.NewEventStore("stores.events", tx, reg),
+			middlewares...,
 		), nil
 	})
 	container.AddScoped("stores", func(c di.Container) (any, error) {
@@ -104,40 +233,63 @@ func (m *Module) Startup(ctx context.Context, mono ms.Microservice) (err error)
 	container.AddScoped("mall", func(c di.Container) (any, error) {
 		return postgres.NewMallRepository("stores.stores", c.Get("tx").(*sql.Tx)), nil
 	})
+	container.AddScoped("history", func(c di.Container) (any, error) {
+		return postgres.NewHistoryRepository(
+			"stores.events",
+			"stores.snapshots",
+			c.Get("tx").(*sql.Tx),
+			c.Get("registry").(registry.Registry),
+		), nil
+	})
+	container.AddScoped("idempotency", func(c di.Container) (any, error) {
+		return postgres.NewIdempotencyStore(
+			"stores.idempotency_keys",
+			c.Get("tx").(*sql.Tx),
+			mono.Config().Idempotency.Retention,
+		), nil
+	})
 
 	// setup application
 	container.AddScoped("app", func(c di.Container) (any, error) {
-		return logging.LogApplicationAccess(
+		return tracing.TraceApplication(metrics.InstrumentApplication(logging.LogApplicationAccess(
 			application.New(
 				c.Get("stores").(domain.StoreRepository),
 				c.Get("products").(domain.ProductRepository),
 				c.Get("catalog").(domain.CatalogRepository),
 				c.Get("mall").(domain.MallRepository),
+				c.Get("history").(domain.HistoryRepository),
 			),
 			c.Get("logger").(zerolog.Logger),
-		), nil
+		))), nil
 	})
 	container.AddScoped("catalogHandlers", func(c di.Container) (any, error) {
-		return logging.LogEventHandlerAccess[ddd.AggregateEvent](
+		return instrumentHandlers(
 			handlers.NewCatalogHandlers(c.Get("catalog").(domain.CatalogRepository)),
 			"Catalog", c.Get("logger").(zerolog.Logger),
 		), nil
 	})
 	container.AddScoped("mallHandlers", func(c di.Container) (any, error) {
-		return logging.LogEventHandlerAccess[ddd.AggregateEvent](
+		return instrumentHandlers(
 			handlers.NewMallHandlers(c.Get("mall").(domain.MallRepository)),
 			"Mall", c.Get("logger").(zerolog.Logger),
 		), nil
 	})
 	container.AddScoped("domainEventHandlers", func(c di.Container) (any, error) {
-		return logging.LogEventHandlerAccess[ddd.AggregateEvent](
+		return instrumentHandlers(
 			handlers.NewDomainEventHandlers(c.Get("eventStream").(am.EventStream)),
 			"DomainEvents", c.Get("logger").(zerolog.Logger),
 		), nil
 	})
 
+	container.AddScoped("stateTransferHandlers", func(c di.Container) (any, error) {
+		return instrumentHandlers(
+			handlers.NewStateTransferHandlers(c.Get("eventStream").(am.EventStream)),
+			"StateTransfer", c.Get("logger").(zerolog.Logger),
+		), nil
+	})
+
 	// setup Driver adapters
-	if err = grpc.RegisterServerTx(container, mono.RPC()); err != nil {
+	if err = grpc.RegisterServer(container, mono.RPC(), mono.Interceptors(), "stores.events", mono.Logger()); err != nil {
 		return err
 	}
 	if err = rest.RegisterGateway(ctx, mono.Mux(), mono.Config().RPC.Address()); err != nil {
@@ -149,14 +301,135 @@ func (m *Module) Startup(ctx context.Context, mono ms.Microservice) (err error)
 	handlers.RegisterCatalogHandlersTx(container)
 	handlers.RegisterMallHandlersTx(container)
 	handlers.RegisterDomainEventHandlersTx(container)
+	if mono.Config().Integration.StateTransfer {
+		handlers.RegisterStateTransferHandlersTx(container)
+	}
 	if err = pb.RegisterAsyncAPI(mono.Mux()); err != nil {
 		return err
 	}
-	startOutboxProcessor(ctx, container)
+	if err = admin.RegisterServer(container, mono.AdminMux(), mono.Config().Admin.Token); err != nil {
+		return err
+	}
+	if err = registerMetrics(mono, container); err != nil {
+		return err
+	}
+	addHealthChecks(mono, container)
+	startOutboxProcessor(mono, container)
+	mono.Waiter().Add(container.Get("idempotencyRetention").(postgres.IdempotencyRetentionJob).Run)
 
 	return nil
 }
 
+// addHealthChecks makes the service unready while the outbox processor is
+// not running
+func addHealthChecks(mono ms.Microservice, container di.Container) {
+	supervisor := container.Get("outboxSupervisor").(*outbox.Supervisor)
+	mono.Health().AddCheck("outbox", func(context.Context) error {
+		if status := supervisor.Status(); status.State != outbox.StateRunning {
+			return fmt.Errorf("the outbox processor is %s", status.State)
+		}
+		return nil
+	})
+}
+
+// registerMetrics registers the collectors that are read when scraped
+func registerMetrics(mono ms.Microservice, container di.Container) error {
+	if err := prometheus.Register(collectors.NewDBStatsCollector(mono.DB(), "stores")); err != nil {
+		return err
+	}
+
+	return prometheus.Register(outbox.NewBacklogCollector(container.Get("outboxStore").(postgres.OutboxStore)))
+}
+
+// instrumentHandlers adds logging, tracing and metrics to event handlers
+func instrumentHandlers(handlers ddd.EventHandler[ddd.AggregateEvent], label string, logger zerolog.Logger) ddd.EventHandler[ddd.AggregateEvent] {
+	return logging.LogEventHandlerAccess[ddd.AggregateEvent](
+		tracing.TraceEventHandlers[ddd.AggregateEvent](
+			metrics.InstrumentEventHandlers[ddd.AggregateEvent](handlers, label),
+			label,
+		),
+		label, logger,
+	)
+}
+
+// RebuildProjections rebuilds the named read models from the event store, or
+// every read model when no names are given
+func RebuildProjections(ctx context.Context, db *sql.DB, logger zerolog.Logger, names ...string) error {
+	reg, err := newRegistry()
+	if err != nil {
+		return err
+	}
+
+	rebuilder := projections.NewRebuilder(db, "stores.events", reg, logger, readModels()...)
+	if len(names) == 0 {
+		names = rebuilder.Names()
+	}
+
+	for _, name := range names {
+		if _, err = rebuilder.Rebuild(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readModels() []projections.Projection {
+	return []projections.Projection{
+		{
+			Name:           "mall",
+			TableName:      "stores.stores",
+			AggregateNames: []string{domain.StoreAggregate},
+			NewHandlers: func(tableName string, tx *sql.Tx) ddd.EventHandler[ddd.AggregateEvent] {
+				return handlers.NewMallHandlers(postgres.NewMallRepository(tableName, tx))
+			},
+		},
+		{
+			Name:           "catalog",
+			TableName:      "stores.products",
+			AggregateNames: []string{domain.ProductAggregate},
+			NewHandlers: func(tableName string, tx *sql.Tx) ddd.EventHandler[ddd.AggregateEvent] {
+				return handlers.NewCatalogHandlers(postgres.NewCatalogRepository(tableName, tx))
+			},
+		},
+	}
+}
+
+func newRegistry() (registry.Registry, error) {
+	reg := upcasting.NewRegistry(registry.New())
+	if err := registrations(reg); err != nil {
+		return nil, err
+	}
+	if err := upcasting.Upcasters(reg); err != nil {
+		return nil, err
+	}
+	if err := pb.Registrations(reg); err != nil {
+		return nil, err
+	}
+	if err := integrationRegistrations(reg); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// integrationRegistrations registers the integration events that are not
+// part of the shared protos
+func integrationRegistrations(reg registry.Registry) (err error) {
+	serde := serdes.NewProtoSerde(reg)
+
+	if err = serde.RegisterKey(handlers.StoreSnapshotEvent, &statepb.StoreSnapshot{}); err != nil {
+		return
+	}
+	if err = serde.RegisterKey(handlers.ProductSnapshotEvent, &statepb.ProductSnapshot{}); err != nil {
+		return
+	}
+	if err = serde.RegisterKey(handlers.ProductTombstoneEvent, &statepb.ProductTombstone{}); err != nil {
+		return
+	}
+
+	return
+}
+
 func registrations(reg registry.Registry) (err error) {
 	serde := serdes.NewJsonSerde(reg)
 
@@ -214,17 +487,89 @@ func registrations(reg registry.Registry) (err error) {
 	if err = serde.RegisterKey(domain.ProductV1{}.SnapshotName(), domain.ProductV1{}); err != nil {
 		return
 	}
+	if err = serde.RegisterKey(domain.ProductV2{}.SnapshotName(), domain.ProductV2{}); err != nil {
+		return
+	}
 
 	return
 }
-func startOutboxProcessor(ctx context.Context, container di.Container) {
-	outboxProcessor := container.Get("outboxProcessor").(tm.OutboxProcessor)
-	logger := container.Get("logger").(zerolog.Logger)
-
-	go func() {
-		err := outboxProcessor.Start(ctx)
-		if err != nil {
-			logger.Error().Err(err).Msg("stores outbox processor encountered an error")
+
+// startOutboxProcessor hands the supervised outbox processor to the waiter,
+// which stops it along with the servers; the outbox is drained after the
+// servers have stopped and before the message stream is drained
+func startOutboxProcessor(mono ms.Microservice, container di.Container) {
+	supervisor := container.Get("outboxSupervisor").(*outbox.Supervisor)
+	mono.Waiter().Add(supervisor.Run)
+	mono.AddDrain(supervisor.Drain)
+	if mono.Config().Outbox.Purge != "disabled" {
+		mono.Waiter().Add(container.Get("outboxRetention").(outbox.RetentionJob).Run)
+	}
+}
+
+func newRetentionConfig(cfg config.OutboxConfig) (outbox.RetentionConfig, error) {
+	retentionCfg := outbox.RetentionConfig{
+		Retention: cfg.Retention,
+		Interval:  cfg.PurgeInterval,
+	}
+
+	switch cfg.Purge {
+	case "", "delete", "disabled":
+	case "archive":
+		retentionCfg.ArchiveTableName = "stores.outbox_archive"
+	default:
+		return retentionCfg, fmt.Errorf("unknown outbox purge mode: %q", cfg.Purge)
+	}
+
+	return retentionCfg, nil
+}
+
+func newEncoding(cfg config.IntegrationConfig) (outbox.Encoding, error) {
+	switch encoding := outbox.Encoding(cfg.Encoding); encoding {
+	case "":
+		return outbox.EncodingStream, nil
+	case outbox.EncodingStream, outbox.EncodingCloudEventsBinary, outbox.EncodingCloudEventsStructured:
+		return encoding, nil
+	default:
+		return "", fmt.Errorf("unknown integration event encoding: %q", cfg.Encoding)
+	}
+}
+
+// defaultCloudEventsSource is the source of CloudEvents when none is set
+const defaultCloudEventsSource = "mallbots/stores"
+
+func cloudEventsSource(cfg config.IntegrationConfig) string {
+	if cfg.CloudEventsSource == "" {
+		return defaultCloudEventsSource
+	}
+
+	return cfg.CloudEventsSource
+}
+
+// defaultSnapshotEvents is used for aggregates without a snapshot config
+const defaultSnapshotEvents = 50
+
+func newSnapshotStrategies(cfg map[string]config.SnapshotConfig) (postgres.SnapshotStrategies, error) {
+	strategies := postgres.SnapshotStrategies{}
+	for _, aggregateName := range []string{domain.StoreAggregate, domain.ProductAggregate} {
+		snapshotCfg := cfg[aggregateName]
+		switch snapshotCfg.Strategy {
+		case "", "events":
+			events := snapshotCfg.Events
+			if events <= 0 {
+				events = defaultSnapshotEvents
+			}
+			strategies[aggregateName] = postgres.EveryNEvents(events)
+		case "interval":
+			if snapshotCfg.Interval <= 0 {
+				return nil, fmt.Errorf("the %s snapshot interval must be greater than zero", aggregateName)
+			}
+			strategies[aggregateName] = postgres.EveryInterval(snapshotCfg.Interval)
+		case "disabled":
+			strategies[aggregateName] = postgres.NoSnapshots()
+		default:
+			return nil, fmt.Errorf("unknown %s snapshot strategy: %q", aggregateName, snapshotCfg.Strategy)
 		}
-	}()
+	}
+
+	return strategies, nil
 }